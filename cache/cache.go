@@ -0,0 +1,46 @@
+// Package cache 缓存对话响应, 相同请求直接返回已缓存的响应而不再请求接口
+// 缓存键为请求的规范化哈希, 存储实现可替换: 内存 LRU(NewMemory) 与磁盘文件(NewDisk)
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/miajio/dpsk/chat"
+)
+
+// keyVersion 缓存键版本, 请求结构或规范化方式变化时递增以废弃旧缓存
+const keyVersion = "dpsk-cache-v1:"
+
+// Store 缓存存储, 实现需可并发使用
+type Store interface {
+	// Get 读取缓存, 不存在或已过期时返回 false
+	Get(key string) ([]byte, bool, error)
+	// Set 写入缓存
+	Set(key string, value []byte) error
+	// Delete 删除缓存, 不存在时不返回错误
+	Delete(key string) error
+}
+
+// Key 计算对话请求的缓存键: 模型、消息、工具及采样参数规范化后的 SHA-256
+// stream 与 stream_options 不参与计算, 同一请求的流式与非流式调用共享缓存
+func Key(req *chat.ChatRequest) (string, error) {
+	r := *req
+	r.Stream = false
+	r.StreamOptions = nil
+	data, err := json.Marshal(&r)
+	if err != nil {
+		return "", err
+	}
+	// 经 any 往返一次, 使工具参数等自定义 JSON 的键顺序与空白一致
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return "", err
+	}
+	if data, err = json.Marshal(value); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(keyVersion), data...))
+	return hex.EncodeToString(sum[:]), nil
+}
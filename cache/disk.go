@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Disk 磁盘缓存, 每个条目保存为目录下的一个文件, 按文件修改时间判断过期
+// 适合在多次运行之间复用缓存, 如离线评测
+type Disk struct {
+	dir string
+	ttl time.Duration
+}
+
+// NewDisk 创建磁盘缓存, 目录不存在时自动创建, ttl 为0时不过期
+func NewDisk(dir string, ttl time.Duration) (*Disk, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Disk{dir: dir, ttl: ttl}, nil
+}
+
+// Get 读取缓存, 过期的文件会被删除
+func (d *Disk) Get(key string) ([]byte, bool, error) {
+	path := d.path(key)
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if d.ttl > 0 && time.Since(info.ModTime()) > d.ttl {
+		return nil, false, d.Delete(key)
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// Set 写入缓存, 先写临时文件再重命名, 避免并发读取到不完整的内容
+func (d *Disk) Set(key string, value []byte) error {
+	tmp, err := os.CreateTemp(d.dir, url.PathEscape(key)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(value); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), d.path(key))
+}
+
+// Delete 删除缓存
+func (d *Disk) Delete(key string) error {
+	if err := os.Remove(d.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// path 返回缓存文件路径, 缓存键经过转义, 避免包含路径分隔符的键写到目录之外
+func (d *Disk) path(key string) string {
+	return filepath.Join(d.dir, url.PathEscape(key)+".json")
+}
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Memory 内存 LRU 缓存, 超过容量时淘汰最久未使用的条目
+type Memory struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	items    map[string]*list.Element
+	order    *list.List // 队首为最近使用
+}
+
+// memoryEntry 内存缓存条目
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemory 创建内存缓存, capacity 为0时不限制条目数, ttl 为0时不过期
+func NewMemory(capacity int, ttl time.Duration) *Memory {
+	return &Memory{
+		capacity: capacity,
+		ttl:      ttl,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Get 读取缓存
+func (m *Memory) Get(key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	elem, ok := m.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := elem.Value.(*memoryEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		m.remove(elem)
+		return nil, false, nil
+	}
+	m.order.MoveToFront(elem)
+	return entry.value, true, nil
+}
+
+// Set 写入缓存
+func (m *Memory) Set(key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry := &memoryEntry{key: key, value: value}
+	if m.ttl > 0 {
+		entry.expires = time.Now().Add(m.ttl)
+	}
+	if elem, ok := m.items[key]; ok {
+		elem.Value = entry
+		m.order.MoveToFront(elem)
+		return nil
+	}
+	m.items[key] = m.order.PushFront(entry)
+	if m.capacity > 0 && m.order.Len() > m.capacity {
+		m.remove(m.order.Back())
+	}
+	return nil
+}
+
+// Delete 删除缓存
+func (m *Memory) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if elem, ok := m.items[key]; ok {
+		m.remove(elem)
+	}
+	return nil
+}
+
+// Len 返回缓存条目数(含未清理的过期条目)
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.order.Len()
+}
+
+// remove 移除条目
+func (m *Memory) remove(elem *list.Element) {
+	m.order.Remove(elem)
+	delete(m.items, elem.Value.(*memoryEntry).key)
+}
@@ -0,0 +1,296 @@
+// Package agent 在 Chat 接口之上实现工具调用循环:
+// 模型返回 tool_calls 时执行已注册的 Go 函数, 将结果以 tool 消息追加到对话中并再次请求, 直到模型给出最终回复
+package agent
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/chat/schema"
+	"github.com/miajio/dpsk/errors"
+)
+
+// ErrMaxSteps 超过最大步数仍未得到最终回复
+var ErrMaxSteps = errors.New("agent: max steps exceeded")
+
+// Client 对话客户端, *engine.Client 实现了该接口
+type Client interface {
+	Chat(ctx context.Context, req *chat.ChatRequest) (*chat.ChatResponse, error)
+}
+
+// HandlerFunc 工具处理函数, arguments 为模型生成的JSON参数, 返回值作为 tool 消息内容
+type HandlerFunc func(ctx context.Context, arguments string) (string, error)
+
+// registeredTool 已注册的工具
+type registeredTool struct {
+	tool    chat.Tool
+	handler HandlerFunc
+}
+
+// Agent 工具调用代理, 可在 Run 执行期间并发注册工具
+type Agent struct {
+	mu          sync.RWMutex // 保护 tools 与 order
+	tools       map[string]registeredTool
+	order       []string
+	maxSteps    int
+	maxParallel int
+	toolTimeout time.Duration
+}
+
+// Option 配置项
+type Option func(*Agent)
+
+// WithMaxSteps 设置最大步数(模型请求次数), 默认10
+func WithMaxSteps(maxSteps int) Option {
+	return func(a *Agent) {
+		a.maxSteps = maxSteps
+	}
+}
+
+// WithMaxParallel 设置同一步内并行执行的工具数, 1为串行执行, 默认不限制
+func WithMaxParallel(maxParallel int) Option {
+	return func(a *Agent) {
+		a.maxParallel = maxParallel
+	}
+}
+
+// WithToolTimeout 设置单个工具的执行超时时间, 默认不限制
+func WithToolTimeout(timeout time.Duration) Option {
+	return func(a *Agent) {
+		a.toolTimeout = timeout
+	}
+}
+
+// New 创建工具调用代理
+func New(options ...Option) *Agent {
+	a := &Agent{
+		tools:    make(map[string]registeredTool),
+		maxSteps: 10,
+	}
+	for _, option := range options {
+		option(a)
+	}
+	return a
+}
+
+// Register 注册工具及其处理函数
+func (a *Agent) Register(tool chat.Tool, handler HandlerFunc) error {
+	name := tool.Function.Name
+	if name == "" {
+		return errors.NewCodeError(http.StatusBadRequest, "tool function name is required")
+	}
+	if handler == nil {
+		return errors.NewCodeErrorF(http.StatusBadRequest, "handler of tool %s is nil", name)
+	}
+	if tool.Type == "" {
+		tool.Type = "function"
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.tools[name]; !ok {
+		a.order = append(a.order, name)
+	}
+	a.tools[name] = registeredTool{tool: tool, handler: handler}
+	return nil
+}
+
+// RegisterFunc 注册函数工具, parameters 为JSON Schema格式的参数定义
+func (a *Agent) RegisterFunc(name, description string, parameters any, handler HandlerFunc) error {
+	return a.Register(chat.Tool{
+		Type:     "function",
+		Function: chat.Function{Name: name, Description: description, Parameters: parameters},
+	}, handler)
+}
+
+// Typed 将强类型处理函数包装为 HandlerFunc, 参数按 T 的 JSON Schema 校验后解码
+// 参数校验失败时将错误返回给模型, 由模型修正后重新调用
+func Typed[T any](handler func(ctx context.Context, args T) (string, error)) HandlerFunc {
+	return func(ctx context.Context, arguments string) (string, error) {
+		args, err := schema.Decode[T](arguments)
+		if err != nil {
+			return "", err
+		}
+		return handler(ctx, args)
+	}
+}
+
+// RegisterTyped 注册强类型函数工具, 参数定义由 T 自动生成
+func RegisterTyped[T any](a *Agent, name, description string, handler func(ctx context.Context, args T) (string, error)) error {
+	parameters, err := schema.For[T]()
+	if err != nil {
+		return err
+	}
+	return a.RegisterFunc(name, description, parameters, Typed(handler))
+}
+
+// Tools 返回已注册的工具定义, 顺序与注册顺序一致
+func (a *Agent) Tools() []chat.Tool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	tools := make([]chat.Tool, 0, len(a.order))
+	for _, name := range a.order {
+		tools = append(tools, a.tools[name].tool)
+	}
+	return tools
+}
+
+// ToolResult 单次工具调用的结果
+type ToolResult struct {
+	Call     chat.ToolCall // 模型生成的工具调用
+	Output   string        // 返回给模型的内容
+	Err      error         // 工具执行错误, 错误信息会作为内容返回给模型
+	Duration time.Duration // 执行耗时
+}
+
+// Step 一次模型请求及其触发的工具调用
+type Step struct {
+	Response    *chat.ChatResponse // 模型响应
+	ToolResults []ToolResult       // 工具调用结果, 最终回复所在的步骤为空
+}
+
+// Result 代理运行结果
+type Result struct {
+	Response *chat.ChatResponse // 最终响应
+	Messages []chat.Message     // 完整的对话记录, 包含工具调用与结果; assistant 消息不含思维链, 可直接用于下一轮请求
+	Steps    []Step             // 每一步的记录
+	Usage    chat.Usage         // 所有请求的用量之和
+}
+
+// Content 返回最终回复内容
+func (r *Result) Content() string {
+	if r.Response == nil || len(r.Response.Choices) == 0 {
+		return ""
+	}
+	return r.Response.Choices[0].Message.Content
+}
+
+// Run 执行 模型→工具→模型 循环, 直到模型给出最终回复或超过最大步数
+// req 不会被修改; req 未设置 Tools 时使用已注册的工具
+// 超过最大步数时返回已完成步骤的 Result 与 ErrMaxSteps
+func (a *Agent) Run(ctx context.Context, client Client, req *chat.ChatRequest) (*Result, error) {
+	if req.Stream {
+		return nil, errors.NewCodeError(http.StatusBadRequest, "agent does not support streaming request")
+	}
+	stepReq := *req
+	stepReq.Messages = append([]chat.Message(nil), req.Messages...)
+	if len(stepReq.Tools) == 0 {
+		stepReq.Tools = a.Tools()
+	}
+
+	result := &Result{}
+	for step := 0; step < a.maxSteps; step++ {
+		resp, err := client.Chat(ctx, &stepReq)
+		if err != nil {
+			result.Messages = stepReq.Messages
+			return result, err
+		}
+		result.Usage.Add(resp.Usage)
+		result.Response = resp
+
+		if len(resp.Choices) == 0 || len(resp.Choices[0].Message.ToolCalls) == 0 {
+			result.Steps = append(result.Steps, Step{Response: resp})
+			if len(resp.Choices) > 0 {
+				stepReq.Messages = append(stepReq.Messages, stripReasoning(resp.Choices[0].Message))
+			}
+			result.Messages = stepReq.Messages
+			return result, nil
+		}
+
+		msg := resp.Choices[0].Message
+		toolResults := a.execute(ctx, msg.ToolCalls)
+		result.Steps = append(result.Steps, Step{Response: resp, ToolResults: toolResults})
+
+		stepReq.Messages = append(stepReq.Messages, stripReasoning(msg))
+		for _, tr := range toolResults {
+			stepReq.Messages = append(stepReq.Messages, chat.NewToolMessage(tr.Call.ID, tr.Output))
+		}
+		if err := ctx.Err(); err != nil {
+			result.Messages = stepReq.Messages
+			return result, err
+		}
+	}
+	result.Messages = stepReq.Messages
+	return result, ErrMaxSteps
+}
+
+// stripReasoning 去除 assistant 消息的思维链, 推理模型不接受历史消息中的思维链
+func stripReasoning(msg chat.Message) chat.Message {
+	return chat.StripReasoning([]chat.Message{msg})[0]
+}
+
+// execute 执行一步中的所有工具调用, 结果顺序与调用顺序一致
+func (a *Agent) execute(ctx context.Context, calls []chat.ToolCall) []ToolResult {
+	results := make([]ToolResult, len(calls))
+	limit := a.maxParallel
+	if limit <= 0 {
+		limit = len(calls)
+	}
+	sem := make(chan struct{}, limit)
+
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = a.call(ctx, call)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// call 执行单个工具调用, 处理函数的错误、panic 与超时会转换为返回给模型的错误信息
+func (a *Agent) call(ctx context.Context, call chat.ToolCall) ToolResult {
+	result := ToolResult{Call: call}
+	start := time.Now()
+
+	a.mu.RLock()
+	tool, ok := a.tools[call.Function.Name]
+	a.mu.RUnlock()
+	if !ok {
+		result.Err = errors.NewF("tool %s is not registered", call.Function.Name)
+	} else {
+		if a.toolTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, a.toolTimeout)
+			defer cancel()
+		}
+		result.Output, result.Err = invoke(ctx, call.Function.Name, tool.handler, call.Function.Arguments)
+	}
+	if result.Err != nil {
+		result.Output = fmt.Sprintf("error: %v", result.Err)
+	}
+	result.Duration = time.Since(start)
+	return result
+}
+
+// invoke 在独立 goroutine 中执行处理函数, ctx 结束时不再等待其返回
+func invoke(ctx context.Context, name string, handler HandlerFunc, arguments string) (string, error) {
+	type outcome struct {
+		output string
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- outcome{err: errors.NewF("tool %s panic: %v", name, r)}
+			}
+		}()
+		output, err := handler(ctx, arguments)
+		done <- outcome{output: output, err: err}
+	}()
+
+	select {
+	case o := <-done:
+		return o.output, o.err
+	case <-ctx.Done():
+		return "", errors.NewF("tool %s canceled: %v", name, ctx.Err())
+	}
+}
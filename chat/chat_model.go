@@ -0,0 +1,80 @@
+package chat
+
+import (
+	"net/http"
+
+	"github.com/miajio/dpsk/errors"
+	"github.com/miajio/dpsk/model"
+)
+
+// samplingParam 可按模型能力剔除的请求参数
+type samplingParam struct {
+	name  string
+	isSet func(cr *ChatRequest) bool
+	clear func(cr *ChatRequest)
+}
+
+var samplingParams = []samplingParam{
+	{model.ParamTemperature, func(cr *ChatRequest) bool { return cr.Temperature != 0 }, func(cr *ChatRequest) { cr.Temperature = 0 }},
+	{model.ParamTopP, func(cr *ChatRequest) bool { return cr.TopP != 0 }, func(cr *ChatRequest) { cr.TopP = 0 }},
+	{model.ParamPresencePenalty, func(cr *ChatRequest) bool { return cr.PresencePenalty != 0 }, func(cr *ChatRequest) { cr.PresencePenalty = 0 }},
+	{model.ParamFrequencyPenalty, func(cr *ChatRequest) bool { return cr.FrequencyPenalty != 0 }, func(cr *ChatRequest) { cr.FrequencyPenalty = 0 }},
+	{model.ParamLogprobs, func(cr *ChatRequest) bool { return cr.Logprobs }, func(cr *ChatRequest) { cr.Logprobs = false }},
+	{model.ParamTopLogprobs, func(cr *ChatRequest) bool { return cr.TopLogprobs != 0 }, func(cr *ChatRequest) { cr.TopLogprobs = 0 }},
+}
+
+// UnsupportedParams 返回请求中已设置但模型不支持(被忽略或会报错)的参数名
+func (cr *ChatRequest) UnsupportedParams(caps model.Capabilities) []string {
+	var params []string
+	for _, p := range samplingParams {
+		if p.isSet(cr) && !caps.Supports(p.name) {
+			params = append(params, p.name)
+		}
+	}
+	return params
+}
+
+// ValidateFor 按模型能力校验请求, 未知模型仅做基础校验
+// 设置了模型不支持的参数、工具、JSON 输出或 max_tokens 超出上限时返回错误
+func (cr *ChatRequest) ValidateFor(caps model.Capabilities) error {
+	if err := cr.Validate(); err != nil {
+		return err
+	}
+	if !caps.Known {
+		return nil
+	}
+	if params := cr.UnsupportedParams(caps); len(params) > 0 {
+		return errors.NewCodeErrorF(http.StatusBadRequest, "model %s does not support parameters %v", caps.ID, params)
+	}
+	return cr.validateFeatures(caps)
+}
+
+// StripUnsupported 按模型能力剔除不支持的采样参数, 返回被剔除的参数名
+// 工具、JSON 输出与 max_tokens 无法剔除, 不满足时仍返回错误
+func (cr *ChatRequest) StripUnsupported(caps model.Capabilities) ([]string, error) {
+	if !caps.Known {
+		return nil, nil
+	}
+	var stripped []string
+	for _, p := range samplingParams {
+		if p.isSet(cr) && !caps.Supports(p.name) {
+			p.clear(cr)
+			stripped = append(stripped, p.name)
+		}
+	}
+	return stripped, cr.validateFeatures(caps)
+}
+
+// validateFeatures 校验工具调用、JSON 输出与输出长度
+func (cr *ChatRequest) validateFeatures(caps model.Capabilities) error {
+	if len(cr.Tools) > 0 && !caps.Tools {
+		return errors.NewCodeErrorF(http.StatusBadRequest, "model %s does not support tools", caps.ID)
+	}
+	if cr.ResponseFormat != nil && cr.ResponseFormat.Type == "json_object" && !caps.JSONMode {
+		return errors.NewCodeErrorF(http.StatusBadRequest, "model %s does not support json output", caps.ID)
+	}
+	if caps.MaxOutput > 0 && cr.MaxTokens > caps.MaxOutput {
+		return errors.NewCodeErrorF(http.StatusBadRequest, "max_tokens %d exceeds the limit %d of model %s", cr.MaxTokens, caps.MaxOutput, caps.ID)
+	}
+	return nil
+}
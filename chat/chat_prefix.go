@@ -0,0 +1,99 @@
+package chat
+
+import (
+	"net/http"
+
+	"github.com/miajio/dpsk/errors"
+	"github.com/miajio/dpsk/model"
+)
+
+// NewPrefixMessage 创建对话前缀续写(beta)的 assistant 前缀消息
+// reasoningContent 仅推理模型可用, 作为思维链的前缀
+func NewPrefixMessage(content, reasoningContent string) Message {
+	return Message{Role: "assistant", Content: content, ReasoningContent: reasoningContent, Prefix: true}
+}
+
+// WithAssistantPrefix 在消息末尾追加 assistant 前缀消息, 模型将从该前缀开始续写
+// 需在 WithMessages 之后使用, 请求会自动发送到 beta 接口
+func WithAssistantPrefix(content string) ChatOption {
+	return func(req *ChatRequest) {
+		req.Messages = append(req.Messages, NewPrefixMessage(content, ""))
+	}
+}
+
+// WithReasoningPrefix 在消息末尾追加带思维链前缀的 assistant 前缀消息, 仅推理模型可用
+// 需在 WithMessages 之后使用, 请求会自动发送到 beta 接口
+func WithReasoningPrefix(reasoningContent, content string) ChatOption {
+	return func(req *ChatRequest) {
+		req.Messages = append(req.Messages, NewPrefixMessage(content, reasoningContent))
+	}
+}
+
+// IsPrefixCompletion 是否为对话前缀续写请求, 即最后一条消息为 assistant 前缀消息
+func (cr *ChatRequest) IsPrefixCompletion() bool {
+	if len(cr.Messages) == 0 {
+		return false
+	}
+	last := cr.Messages[len(cr.Messages)-1]
+	return last.Role == "assistant" && last.Prefix
+}
+
+// PrefixMessage 返回末尾的前缀消息, 不是前缀续写请求时 ok 为 false
+func (cr *ChatRequest) PrefixMessage() (msg Message, ok bool) {
+	if !cr.IsPrefixCompletion() {
+		return Message{}, false
+	}
+	return cr.Messages[len(cr.Messages)-1], true
+}
+
+// ValidatePrefix 校验对话前缀续写请求
+// 最后一条消息必须是 prefix 为 true 的 assistant 消息, 其余消息不能设置 prefix, 模型需支持前缀续写
+func (cr *ChatRequest) ValidatePrefix() error {
+	if err := cr.Validate(); err != nil {
+		return err
+	}
+	if !cr.IsPrefixCompletion() {
+		return errors.NewCodeError(http.StatusBadRequest, "the last message must be an assistant message with prefix")
+	}
+	for i, msg := range cr.Messages[:len(cr.Messages)-1] {
+		if msg.Prefix {
+			return errors.NewCodeErrorF(http.StatusBadRequest, "only the last message can be a prefix, got message %d", i)
+		}
+	}
+	last := cr.Messages[len(cr.Messages)-1]
+	caps, ok := model.LookupCapabilities(cr.Model)
+	if ok && !caps.PrefixCompletion {
+		return errors.NewCodeErrorF(http.StatusBadRequest, "model %s does not support prefix completion", cr.Model)
+	}
+	if last.ReasoningContent != "" && ok && !caps.Reasoning {
+		return errors.NewCodeErrorF(http.StatusBadRequest, "reasoning prefix is only supported by reasoning models, got %s", cr.Model)
+	}
+	return nil
+}
+
+// PrefixResponse 对话前缀续写的响应, 内嵌的 ChatResponse 中为模型续写的部分
+type PrefixResponse struct {
+	*ChatResponse
+	Prefix Message // 请求中的前缀消息
+}
+
+// Continuation 返回续写的内容, 不含前缀
+func (r *PrefixResponse) Continuation() string {
+	return r.ChatResponse.Content()
+}
+
+// FullContent 返回前缀与续写拼接后的完整内容
+func (r *PrefixResponse) FullContent() string {
+	return r.Prefix.Content + r.ChatResponse.Content()
+}
+
+// FullReasoning 返回思维链前缀与续写的思维链拼接后的完整内容
+func (r *PrefixResponse) FullReasoning() string {
+	return r.Prefix.ReasoningContent + r.ChatResponse.Reasoning()
+}
+
+// Message 返回前缀与续写合并后的 assistant 消息, 可直接放回历史
+// 推理模型不接受历史消息中的思维链, 返回的消息不含思维链, 需要时通过 FullReasoning 获取
+func (r *PrefixResponse) Message() Message {
+	return Message{Role: "assistant", Content: r.FullContent()}
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/miajio/dpsk/errors"
+	"github.com/miajio/dpsk/model"
 )
 
 // ChatReq 对话请求
@@ -39,9 +40,56 @@ func (cr *ChatRequest) Validate() error {
 		return errors.NewCodeError(http.StatusBadRequest, "model is required")
 	}
 
+	if caps, ok := model.LookupCapabilities(cr.Model); ok && caps.Reasoning {
+		return cr.validateReasoning(caps)
+	}
+	return nil
+}
+
+// validateReasoning 推理模型的校验: 不接受 logprobs 等参数, 思维链内容只能出现在末尾的前缀续写消息中
+func (cr *ChatRequest) validateReasoning(caps model.Capabilities) error {
+	for _, p := range samplingParams {
+		if p.isSet(cr) && caps.Rejects(p.name) {
+			return errors.NewCodeErrorF(http.StatusBadRequest, "%s is not supported by model %s", p.name, cr.Model)
+		}
+	}
+	for i, msg := range cr.Messages {
+		if msg.ReasoningContent != "" && !(i == len(cr.Messages)-1 && msg.Role == "assistant" && msg.Prefix) {
+			return errors.NewCodeErrorF(http.StatusBadRequest,
+				"reasoning_content of message %d must not be sent back to model %s, use chat.StripReasoning", i, cr.Model)
+		}
+	}
 	return nil
 }
 
+// Warnings 返回请求中设置了但对所选模型不生效的参数提示, 如推理模型的 temperature
+func (cr *ChatRequest) Warnings() []string {
+	caps, ok := model.LookupCapabilities(cr.Model)
+	if !ok {
+		return nil
+	}
+	var warnings []string
+	for _, p := range samplingParams {
+		if p.isSet(cr) && caps.Ignores(p.name) {
+			warnings = append(warnings, p.name+" has no effect on model "+cr.Model)
+		}
+	}
+	return warnings
+}
+
+// StripReasoning 返回去除思维链内容的消息副本, 用于将推理模型的回复放回历史
+// 末尾的 assistant 前缀续写消息保留思维链内容
+func StripReasoning(msgs []Message) []Message {
+	stripped := make([]Message, len(msgs))
+	for i, msg := range msgs {
+		if !(i == len(msgs)-1 && msg.Role == "assistant" && msg.Prefix) {
+			msg.ReasoningContent = ""
+		}
+		stripped[i] = msg
+	}
+	return stripped
+}
+
 // AddMessage 添加消息
 func (cr *ChatRequest) AddMessage(role, content string) error {
 	msg := Message{Role: role, Content: content}
@@ -52,6 +100,11 @@ func (cr *ChatRequest) AddMessage(role, content string) error {
 	return nil
 }
 
+// AddToolMessage 添加工具调用结果消息
+func (cr *ChatRequest) AddToolMessage(toolCallId, content string) error {
+	return cr.AddMessages(NewToolMessage(toolCallId, content))
+}
+
 // AddMessageObj 添加消息对象
 func (cr *ChatRequest) AddMessages(msgs ...Message) error {
 	for _, msg := range msgs {
@@ -65,12 +118,13 @@ func (cr *ChatRequest) AddMessages(msgs ...Message) error {
 
 // MessageReq 对话消息请求
 type Message struct {
-	Role             string `json:"role"`                        // 角色
-	Content          string `json:"content"`                     // 内容
-	Name             string `json:"name,omitempty"`              // 名称
-	Prefix           bool   `json:"prefix,omitempty"`            // 是否为前缀
-	ReasoningContent string `json:"reasoning_content,omitempty"` // 对话前续写下用于assistant思维链内容输入
-	ToolClassId      string `json:"tool_class_id,omitempty"`     // 此消息所响应的 tool call 的 ID
+	Role             string     `json:"role"`                        // 角色
+	Content          string     `json:"content"`                     // 内容
+	Name             string     `json:"name,omitempty"`              // 名称
+	Prefix           bool       `json:"prefix,omitempty"`            // 是否为前缀
+	ReasoningContent string     `json:"reasoning_content,omitempty"` // 对话前续写下用于assistant思维链内容输入
+	ToolCallId       string     `json:"tool_call_id,omitempty"`      // 此消息所响应的 tool call 的 ID(role 为 tool 时必填)
+	ToolCalls        []ToolCall `json:"tool_calls,omitempty"`        // 模型生成的工具调用
 }
 
 // Validate 验证消息参数
@@ -78,12 +132,26 @@ func (m *Message) Validate() error {
 	if m.Role == "" {
 		return errors.NewCodeError(http.StatusBadRequest, "role is required")
 	}
-	if m.Content == "" {
+	// assistant 的工具调用消息与只有思维链前缀的前缀消息可以没有内容
+	if m.Content == "" && !(m.Role == "assistant" && (len(m.ToolCalls) > 0 || m.Prefix && m.ReasoningContent != "")) {
 		return errors.NewCodeError(http.StatusBadRequest, "content is required")
 	}
+	if m.Role == "tool" && m.ToolCallId == "" {
+		return errors.NewCodeError(http.StatusBadRequest, "tool_call_id is required for tool message")
+	}
+	for _, call := range m.ToolCalls {
+		if call.ID == "" || call.Function.Name == "" {
+			return errors.NewCodeError(http.StatusBadRequest, "tool call id and function name are required")
+		}
+	}
 	return nil
 }
 
+// NewToolMessage 创建工具调用结果消息
+func NewToolMessage(toolCallId, content string) Message {
+	return Message{Role: "tool", Content: content, ToolCallId: toolCallId}
+}
+
 // ResponseFormat 输出格式结构体
 type ResponseFormat struct {
 	Type string `json:"type,omitempty"` // 输出格式类型，如 "text" 或 "json_object"
@@ -106,3 +174,17 @@ type Function struct {
 	Description string `json:"description"` // 函数描述
 	Parameters  any    `json:"parameters"`  // 函数参数(JSON Schema格式)
 }
+
+// ToolCall 模型生成的工具调用
+type ToolCall struct {
+	Index    int          `json:"index,omitempty"` // 工具调用序号, 流式增量中用于拼接同一工具调用的片段
+	ID       string       `json:"id,omitempty"`    // 工具调用ID
+	Type     string       `json:"type,omitempty"`  // 工具类型(通常为"function")
+	Function FunctionCall `json:"function"`        // 调用的函数
+}
+
+// FunctionCall 模型调用的函数
+type FunctionCall struct {
+	Name      string `json:"name,omitempty"` // 函数名称
+	Arguments string `json:"arguments"`      // 函数参数(JSON格式字符串), 流式增量中为参数片段
+}
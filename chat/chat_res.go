@@ -54,3 +54,29 @@ type ChatResponse struct {
 	Object            string   `json:"object"`             // 对象类型
 	Usage             Usage    `json:"usage"`              // 消耗的用量
 }
+
+// Add 累加用量
+func (u *Usage) Add(other Usage) {
+	u.CompletionTokens += other.CompletionTokens
+	u.PromptTokens += other.PromptTokens
+	u.PromptCacheHitTokens += other.PromptCacheHitTokens
+	u.PromptCacheMissTokens += other.PromptCacheMissTokens
+	u.TotalTokens += other.TotalTokens
+	u.CompletionTokensDetails.ReasoningTokens += other.CompletionTokensDetails.ReasoningTokens
+}
+
+// Content 返回第一个选项的回复内容, 流式响应块返回增量内容
+func (r *ChatResponse) Content() string {
+	if len(r.Choices) == 0 {
+		return ""
+	}
+	return r.Choices[0].Message.Content + r.Choices[0].Delta.Content
+}
+
+// Reasoning 返回第一个选项的思维链内容(仅推理模型), 流式响应块返回增量内容
+func (r *ChatResponse) Reasoning() string {
+	if len(r.Choices) == 0 {
+		return ""
+	}
+	return r.Choices[0].Message.ReasoningContent + r.Choices[0].Delta.ReasoningContent
+}
@@ -0,0 +1,135 @@
+package chat
+
+import (
+	"sort"
+	"strings"
+)
+
+// StreamAccumulator 流式响应累加器
+// 按 choice 序号合并增量内容、思维链与工具调用片段, 生成与非流式 Chat 相同结构的 ChatResponse
+type StreamAccumulator struct {
+	response ChatResponse
+	choices  map[int]*choiceBuilder
+}
+
+// choiceBuilder 单个 choice 的累加状态
+type choiceBuilder struct {
+	choice    Choice
+	content   strings.Builder
+	reasoning strings.Builder
+	toolCalls map[int]*toolCallBuilder
+}
+
+// toolCallBuilder 单个工具调用的累加状态
+type toolCallBuilder struct {
+	call      ToolCall
+	arguments strings.Builder
+}
+
+// NewStreamAccumulator 创建流式响应累加器
+func NewStreamAccumulator() *StreamAccumulator {
+	return &StreamAccumulator{choices: make(map[int]*choiceBuilder)}
+}
+
+// Add 添加一个流式响应块
+func (a *StreamAccumulator) Add(chunk ChatResponse) {
+	if a.response.ID == "" {
+		a.response.ID = chunk.ID
+	}
+	if a.response.Created == 0 {
+		a.response.Created = chunk.Created
+	}
+	if a.response.Model == "" {
+		a.response.Model = chunk.Model
+	}
+	if a.response.SystemFingerprint == "" {
+		a.response.SystemFingerprint = chunk.SystemFingerprint
+	}
+	// 开启 include_usage 时, 最后一个块携带整个请求的用量
+	if chunk.Usage.TotalTokens > 0 {
+		a.response.Usage = chunk.Usage
+	}
+
+	for _, choice := range chunk.Choices {
+		builder, ok := a.choices[choice.Index]
+		if !ok {
+			builder = &choiceBuilder{
+				choice:    Choice{Index: choice.Index},
+				toolCalls: make(map[int]*toolCallBuilder),
+			}
+			a.choices[choice.Index] = builder
+		}
+		builder.add(choice)
+	}
+}
+
+// add 合并 choice 增量
+func (b *choiceBuilder) add(choice Choice) {
+	delta := choice.Delta
+	if delta.Role != "" {
+		b.choice.Message.Role = delta.Role
+	}
+	b.content.WriteString(delta.Content)
+	b.reasoning.WriteString(delta.ReasoningContent)
+	for _, call := range delta.ToolCalls {
+		tb, ok := b.toolCalls[call.Index]
+		if !ok {
+			tb = &toolCallBuilder{call: ToolCall{Index: call.Index}}
+			b.toolCalls[call.Index] = tb
+		}
+		if call.ID != "" {
+			tb.call.ID = call.ID
+		}
+		if call.Type != "" {
+			tb.call.Type = call.Type
+		}
+		if call.Function.Name != "" {
+			tb.call.Function.Name = call.Function.Name
+		}
+		tb.arguments.WriteString(call.Function.Arguments)
+	}
+	if choice.FinishReason != "" {
+		b.choice.FinishReason = choice.FinishReason
+	}
+	b.choice.Logprobs.Content = append(b.choice.Logprobs.Content, choice.Logprobs.Content...)
+}
+
+// build 生成最终的 choice
+func (b *choiceBuilder) build() Choice {
+	choice := b.choice
+	if choice.Message.Role == "" {
+		choice.Message.Role = "assistant"
+	}
+	choice.Message.Content = b.content.String()
+	choice.Message.ReasoningContent = b.reasoning.String()
+
+	indexes := make([]int, 0, len(b.toolCalls))
+	for index := range b.toolCalls {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	for _, index := range indexes {
+		tb := b.toolCalls[index]
+		call := tb.call
+		call.Function.Arguments = tb.arguments.String()
+		choice.Message.ToolCalls = append(choice.Message.ToolCalls, call)
+	}
+	return choice
+}
+
+// Response 返回当前已合并的完整响应
+func (a *StreamAccumulator) Response() *ChatResponse {
+	response := a.response
+	response.Object = "chat.completion"
+
+	indexes := make([]int, 0, len(a.choices))
+	for index := range a.choices {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	response.Choices = make([]Choice, 0, len(indexes))
+	for _, index := range indexes {
+		response.Choices = append(response.Choices, a.choices[index].build())
+	}
+	return &response
+}
@@ -0,0 +1,259 @@
+// Package conversation 在 Chat 接口之上管理多轮对话:
+// 会话持有系统提示词、消息历史与默认请求参数, 发送消息后自动追加模型回复, 并通过 Store 持久化
+package conversation
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/engine"
+	"github.com/miajio/dpsk/errors"
+)
+
+// ChatClient 非流式对话客户端
+type ChatClient interface {
+	Chat(ctx context.Context, req *chat.ChatRequest) (*chat.ChatResponse, error)
+}
+
+// Client 对话客户端, *engine.Client 实现了该接口
+type Client interface {
+	ChatClient
+	ChatStream(ctx context.Context, req *chat.ChatRequest) (*engine.Stream[chat.ChatResponse], error)
+}
+
+// Option 配置项
+type Option func(*Conversation)
+
+// WithStore 设置会话存储, 默认使用内存存储
+func WithStore(store Store) Option {
+	return func(c *Conversation) {
+		c.store = store
+	}
+}
+
+// WithSystemPrompt 设置系统提示词, 仅对新建的会话生效
+func WithSystemPrompt(prompt string) Option {
+	return func(c *Conversation) {
+		c.systemPrompt = prompt
+	}
+}
+
+// WithRequestOptions 设置每次请求的默认参数, 如模型、温度等
+func WithRequestOptions(options ...chat.ChatOption) Option {
+	return func(c *Conversation) {
+		c.requestOptions = append(c.requestOptions, options...)
+	}
+}
+
+// Conversation 多轮对话, 同一会话的发送操作串行执行, 可在多个 goroutine 中使用
+type Conversation struct {
+	mu             sync.Mutex
+	client         Client
+	store          Store
+	session        *Session
+	systemPrompt   string
+	requestOptions []chat.ChatOption
+	strategies     []HistoryStrategy
+}
+
+// New 创建或加载会话, 存储中已存在该会话时恢复其历史
+func New(ctx context.Context, client Client, sessionID string, options ...Option) (*Conversation, error) {
+	if sessionID == "" {
+		return nil, errors.NewCodeError(http.StatusBadRequest, "session id is required")
+	}
+	c := &Conversation{client: client}
+	for _, option := range options {
+		option(c)
+	}
+	if c.store == nil {
+		c.store = NewMemoryStore()
+	}
+
+	session, err := c.store.Load(ctx, sessionID)
+	if err == ErrSessionNotFound {
+		now := time.Now()
+		session = &Session{ID: sessionID, SystemPrompt: c.systemPrompt, CreatedAt: now, UpdatedAt: now}
+	} else if err != nil {
+		return nil, err
+	}
+	c.session = session
+	return c, nil
+}
+
+// ID 返回会话ID
+func (c *Conversation) ID() string {
+	return c.session.ID
+}
+
+// History 返回消息历史的副本, 不含系统提示词
+func (c *Conversation) History() []chat.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]chat.Message(nil), c.session.Messages...)
+}
+
+// Reset 清空消息历史并保存, 保留系统提示词
+func (c *Conversation) Reset(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.session.Messages = nil
+	c.session.UpdatedAt = time.Now()
+	return c.store.Save(ctx, c.session)
+}
+
+// Send 发送用户消息, 返回模型回复
+func (c *Conversation) Send(ctx context.Context, content string) (*chat.ChatResponse, error) {
+	return c.SendMessages(ctx, chat.Message{Role: "user", Content: content})
+}
+
+// SendMessages 发送消息(如用户消息或工具调用结果), 成功后将消息与模型回复追加到历史并保存
+// 配置了历史管理策略时, 保存的是经过策略处理后的历史; 请求失败时历史保持不变
+func (c *Conversation) SendMessages(ctx context.Context, msgs ...chat.Message) (*chat.ChatResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	req, history, err := c.newRequest(ctx, false, msgs)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.commit(ctx, history, resp); err != nil {
+		return resp, err
+	}
+	return resp, nil
+}
+
+// SendStream 以流式方式发送用户消息, 每收到一个响应块调用一次 onChunk
+// 流结束后将合并的完整回复追加到历史并保存; onChunk 返回错误时中止流, 历史保持不变
+func (c *Conversation) SendStream(ctx context.Context, content string, onChunk func(chunk chat.ChatResponse) error) (*chat.ChatResponse, error) {
+	return c.SendMessagesStream(ctx, onChunk, chat.Message{Role: "user", Content: content})
+}
+
+// SendMessagesStream 以流式方式发送消息, 参见 SendStream
+func (c *Conversation) SendMessagesStream(ctx context.Context, onChunk func(chunk chat.ChatResponse) error, msgs ...chat.Message) (*chat.ChatResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	req, history, err := c.newRequest(ctx, true, msgs)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := c.client.ChatStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	acc := chat.NewStreamAccumulator()
+	for stream.Next() {
+		chunk := stream.Current()
+		acc.Add(chunk)
+		if onChunk != nil {
+			if err := onChunk(chunk); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+
+	resp := acc.Response()
+	if err := c.commit(ctx, history, resp); err != nil {
+		return resp, err
+	}
+	return resp, nil
+}
+
+// newRequest 由系统提示词、历史与新消息构造请求, 返回经过历史管理策略处理后的历史(含新消息)
+func (c *Conversation) newRequest(ctx context.Context, stream bool, msgs []chat.Message) (*chat.ChatRequest, []chat.Message, error) {
+	for _, msg := range msgs {
+		if err := msg.Validate(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	history := make([]chat.Message, 0, len(c.session.Messages)+len(msgs))
+	history = append(history, c.session.Messages...)
+	history = append(history, msgs...)
+	for _, strategy := range c.strategies {
+		var err error
+		if history, err = strategy.Apply(ctx, c.session.SystemPrompt, history); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	messages := make([]chat.Message, 0, len(history)+1)
+	if c.session.SystemPrompt != "" {
+		messages = append(messages, chat.Message{Role: "system", Content: c.session.SystemPrompt})
+	}
+	// 推理模型的思维链内容不能放回上下文, 历史中保留完整回复, 仅在请求中去除
+	messages = append(messages, chat.StripReasoning(history)...)
+
+	options := append([]chat.ChatOption(nil), c.requestOptions...)
+	options = append(options, chat.WithMessages(messages...), chat.WithStream(stream))
+	req, err := chat.NewChatRequest(options...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return req, history, nil
+}
+
+// commit 将模型回复追加到历史并保存
+func (c *Conversation) commit(ctx context.Context, history []chat.Message, resp *chat.ChatResponse) error {
+	if len(resp.Choices) > 0 {
+		reply := resp.Choices[0].Message
+		if reply.Role == "" {
+			reply.Role = "assistant"
+		}
+		history = append(history, reply)
+	}
+	c.session.Messages = history
+	c.session.UpdatedAt = time.Now()
+	return c.store.Save(ctx, c.session)
+}
+
+// Manager 按会话ID管理对话, 同一会话ID返回同一个 Conversation
+type Manager struct {
+	mu            sync.Mutex
+	client        Client
+	options       []Option
+	conversations map[string]*Conversation
+}
+
+// NewManager 创建会话管理器, options 应用于其创建的每个会话
+func NewManager(client Client, options ...Option) *Manager {
+	return &Manager{
+		client:        client,
+		options:       options,
+		conversations: make(map[string]*Conversation),
+	}
+}
+
+// Get 获取或加载会话
+func (m *Manager) Get(ctx context.Context, sessionID string) (*Conversation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c, ok := m.conversations[sessionID]; ok {
+		return c, nil
+	}
+	c, err := New(ctx, m.client, sessionID, m.options...)
+	if err != nil {
+		return nil, err
+	}
+	m.conversations[sessionID] = c
+	return c, nil
+}
+
+// Remove 从管理器中移除会话, 不影响存储中的数据
+func (m *Manager) Remove(sessionID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.conversations, sessionID)
+}
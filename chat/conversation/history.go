@@ -0,0 +1,173 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/engine"
+	"github.com/miajio/dpsk/errors"
+)
+
+// HistoryStrategy 历史管理策略, 在每次请求前处理消息历史(含本次发送的消息, 不含系统提示词)
+// 返回的消息作为本次请求的上下文, 并替换会话中保存的历史
+type HistoryStrategy interface {
+	Apply(ctx context.Context, systemPrompt string, history []chat.Message) ([]chat.Message, error)
+}
+
+// HistoryStrategyFunc 函数形式的历史管理策略
+type HistoryStrategyFunc func(ctx context.Context, systemPrompt string, history []chat.Message) ([]chat.Message, error)
+
+// Apply 执行策略
+func (f HistoryStrategyFunc) Apply(ctx context.Context, systemPrompt string, history []chat.Message) ([]chat.Message, error) {
+	return f(ctx, systemPrompt, history)
+}
+
+// WithHistoryStrategy 设置历史管理策略, 多个策略按顺序执行
+func WithHistoryStrategy(strategies ...HistoryStrategy) Option {
+	return func(c *Conversation) {
+		c.strategies = append(c.strategies, strategies...)
+	}
+}
+
+// TokenCounter 计算消息的token数
+type TokenCounter func(msgs []chat.Message) int
+
+// EstimateTokens 默认的token估算方式, 按字符数粗略估算
+func EstimateTokens(msgs []chat.Message) int {
+	return engine.EstimateTokens(&chat.ChatRequest{Messages: msgs})
+}
+
+// splitTurns 按轮次切分消息, 每个 user 消息开始新的一轮
+// 工具调用与其结果总在同一轮中, 裁剪时不会被拆开
+func splitTurns(msgs []chat.Message) [][]chat.Message {
+	var turns [][]chat.Message
+	for i, msg := range msgs {
+		if i == 0 || msg.Role == "user" {
+			turns = append(turns, nil)
+		}
+		turns[len(turns)-1] = append(turns[len(turns)-1], msg)
+	}
+	return turns
+}
+
+// joinTurns 合并轮次
+func joinTurns(turns [][]chat.Message) []chat.Message {
+	var msgs []chat.Message
+	for _, turn := range turns {
+		msgs = append(msgs, turn...)
+	}
+	return msgs
+}
+
+// KeepLastTurns 仅保留最近 n 轮对话
+func KeepLastTurns(n int) HistoryStrategy {
+	return HistoryStrategyFunc(func(ctx context.Context, systemPrompt string, history []chat.Message) ([]chat.Message, error) {
+		turns := splitTurns(history)
+		if n <= 0 || len(turns) <= n {
+			return history, nil
+		}
+		return joinTurns(turns[len(turns)-n:]), nil
+	})
+}
+
+// TokenBudget 滑动窗口, 从最早的轮次开始丢弃, 直到系统提示词与历史的token数不超过 maxTokens
+// 最近一轮总会保留; counter 为空时使用 EstimateTokens
+func TokenBudget(maxTokens int, counter TokenCounter) HistoryStrategy {
+	if counter == nil {
+		counter = EstimateTokens
+	}
+	return HistoryStrategyFunc(func(ctx context.Context, systemPrompt string, history []chat.Message) ([]chat.Message, error) {
+		systemTokens := 0
+		if systemPrompt != "" {
+			systemTokens = counter([]chat.Message{{Role: "system", Content: systemPrompt}})
+		}
+		turns := splitTurns(history)
+		for len(turns) > 1 && systemTokens+counter(joinTurns(turns)) > maxTokens {
+			turns = turns[1:]
+		}
+		return joinTurns(turns), nil
+	})
+}
+
+// defaultSummaryPrompt 默认的摘要提示词
+const defaultSummaryPrompt = "请用简洁的中文总结以下对话的关键信息(人物、事实、偏好、未完成的事项), 供后续对话参考, 只输出摘要内容。"
+
+// summaryPrefix 摘要消息的内容前缀
+const summaryPrefix = "以下是之前对话的摘要:\n"
+
+// Summarizer 摘要策略, 历史超过token阈值时, 使用一次额外的 Chat 请求将较早的轮次总结为一条 system 消息
+type Summarizer struct {
+	Client    ChatClient        // 用于生成摘要的客户端
+	Options   []chat.ChatOption // 摘要请求的参数, 需包含模型
+	MaxTokens int               // 触发摘要的token阈值(含系统提示词)
+	KeepTurns int               // 保留原文的最近轮数, 默认2
+	Prompt    string            // 摘要提示词, 为空时使用默认提示词
+	Counter   TokenCounter      // token计算方式, 为空时使用 EstimateTokens
+}
+
+// Apply 执行摘要策略
+func (s *Summarizer) Apply(ctx context.Context, systemPrompt string, history []chat.Message) ([]chat.Message, error) {
+	counter := s.Counter
+	if counter == nil {
+		counter = EstimateTokens
+	}
+	keepTurns := s.KeepTurns
+	if keepTurns <= 0 {
+		keepTurns = 2
+	}
+
+	tokens := counter(history)
+	if systemPrompt != "" {
+		tokens += counter([]chat.Message{{Role: "system", Content: systemPrompt}})
+	}
+	turns := splitTurns(history)
+	if tokens <= s.MaxTokens || len(turns) <= keepTurns {
+		return history, nil
+	}
+
+	older := joinTurns(turns[:len(turns)-keepTurns])
+	summary, err := s.summarize(ctx, older)
+	if err != nil {
+		return nil, err
+	}
+	result := []chat.Message{{Role: "system", Content: summaryPrefix + summary}}
+	return append(result, joinTurns(turns[len(turns)-keepTurns:])...), nil
+}
+
+// summarize 请求模型生成摘要
+func (s *Summarizer) summarize(ctx context.Context, msgs []chat.Message) (string, error) {
+	prompt := s.Prompt
+	if prompt == "" {
+		prompt = defaultSummaryPrompt
+	}
+
+	var transcript strings.Builder
+	for _, msg := range msgs {
+		content := strings.TrimPrefix(msg.Content, summaryPrefix)
+		if content == "" {
+			continue
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n", msg.Role, content)
+	}
+
+	options := append([]chat.ChatOption(nil), s.Options...)
+	options = append(options, chat.WithMessages(
+		chat.Message{Role: "system", Content: prompt},
+		chat.Message{Role: "user", Content: transcript.String()},
+	))
+	req, err := chat.NewChatRequest(options...)
+	if err != nil {
+		return "", err
+	}
+	resp, err := s.Client.Chat(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 || resp.Choices[0].Message.Content == "" {
+		return "", errors.NewCodeError(http.StatusBadGateway, "empty summary response")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
@@ -0,0 +1,148 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/errors"
+)
+
+// ErrSessionNotFound 会话不存在
+var ErrSessionNotFound = errors.NewCodeError(http.StatusNotFound, "session not found")
+
+// Session 会话数据
+type Session struct {
+	ID           string         `json:"id"`                      // 会话ID
+	SystemPrompt string         `json:"system_prompt,omitempty"` // 系统提示词
+	Messages     []chat.Message `json:"messages"`                // 消息历史, 不含系统提示词
+	CreatedAt    time.Time      `json:"created_at"`              // 创建时间
+	UpdatedAt    time.Time      `json:"updated_at"`              // 更新时间
+}
+
+// clone 复制会话, 避免存储与调用方共享消息切片
+func (s *Session) clone() *Session {
+	c := *s
+	c.Messages = append([]chat.Message(nil), s.Messages...)
+	return &c
+}
+
+// Store 会话存储, 可基于 SQL、Redis 等实现
+// Load 在会话不存在时返回 ErrSessionNotFound
+type Store interface {
+	Load(ctx context.Context, id string) (*Session, error)
+	Save(ctx context.Context, session *Session) error
+	Delete(ctx context.Context, id string) error
+}
+
+// MemoryStore 内存存储
+type MemoryStore struct {
+	mu       sync.RWMutex
+	sessions map[string]*Session
+}
+
+// NewMemoryStore 创建内存存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]*Session)}
+}
+
+// Load 读取会话
+func (s *MemoryStore) Load(ctx context.Context, id string) (*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return session.clone(), nil
+}
+
+// Save 保存会话
+func (s *MemoryStore) Save(ctx context.Context, session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[session.ID] = session.clone()
+	return nil
+}
+
+// Delete 删除会话
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+	return nil
+}
+
+// FileStore 文件存储, 每个会话保存为目录下的一个JSON文件
+type FileStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileStore 创建文件存储, 目录不存在时自动创建
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// path 返回会话文件路径, 会话ID经过转义避免路径穿越
+func (s *FileStore) path(id string) string {
+	return filepath.Join(s.dir, url.PathEscape(id)+".json")
+}
+
+// Load 读取会话
+func (s *FileStore) Load(ctx context.Context, id string) (*Session, error) {
+	data, err := os.ReadFile(s.path(id))
+	if os.IsNotExist(err) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Save 保存会话, 先写入临时文件再重命名, 避免写入中断导致文件损坏
+func (s *FileStore) Save(ctx context.Context, session *Session) error {
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp, err := os.CreateTemp(s.dir, ".session-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(session.ID))
+}
+
+// Delete 删除会话
+func (s *FileStore) Delete(ctx context.Context, id string) error {
+	err := os.Remove(s.path(id))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
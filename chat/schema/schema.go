@@ -0,0 +1,437 @@
+// Package schema 根据 Go 结构体生成 JSON Schema, 用于 chat.Function 的 Parameters,
+// 并将模型生成的工具调用参数校验后解码为对应的结构体
+//
+// 字段名取自 json 标签, 其余约束通过以下标签声明:
+//
+//	type Args struct {
+//		City string `json:"city" description:"城市名称" jsonschema:"required"`
+//		Unit string `json:"unit" jsonschema:"enum=celsius|fahrenheit"`
+//		Days int    `json:"days" jsonschema:"minimum=1,maximum=7"`
+//	}
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/errors"
+)
+
+// Schema JSON Schema
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`                 // 类型: object/array/string/integer/number/boolean
+	Description          string             `json:"description,omitempty"`          // 描述
+	Properties           map[string]*Schema `json:"properties,omitempty"`           // 对象属性
+	Required             []string           `json:"required,omitempty"`             // 必填属性
+	Items                *Schema            `json:"items,omitempty"`                // 数组元素
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"` // map 的值类型
+	Enum                 []any              `json:"enum,omitempty"`                 // 枚举值
+	Format               string             `json:"format,omitempty"`               // 格式, 如 date-time
+	Minimum              *float64           `json:"minimum,omitempty"`              // 最小值
+	Maximum              *float64           `json:"maximum,omitempty"`              // 最大值
+
+	nullable bool // 是否允许 null, 由指针、切片、map 与 interface 类型生成的 Schema 允许
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// decodeSchemas Decode 使用的 Schema 缓存, key 为 reflect.Type
+var decodeSchemas sync.Map
+
+// Generate 根据值的类型生成 JSON Schema, v 可以是结构体值、结构体指针或 reflect.Type
+func Generate(v any) (*Schema, error) {
+	t, ok := v.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(v)
+	}
+	if t == nil {
+		return nil, errors.NewCodeError(http.StatusBadRequest, "schema: nil type")
+	}
+	return generate(t, make(map[reflect.Type]bool))
+}
+
+// For 根据类型参数生成 JSON Schema
+func For[T any]() (*Schema, error) {
+	return Generate(reflect.TypeFor[T]())
+}
+
+// MustFor 与 For 相同, 出错时 panic, 适用于包级变量初始化
+func MustFor[T any]() *Schema {
+	s, err := For[T]()
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// NewTool 根据参数类型创建函数工具定义
+func NewTool[T any](name, description string) (chat.Tool, error) {
+	s, err := For[T]()
+	if err != nil {
+		return chat.Tool{}, err
+	}
+	return chat.Tool{
+		Type:     "function",
+		Function: chat.Function{Name: name, Description: description, Parameters: s},
+	}, nil
+}
+
+// generate 递归生成 Schema, visiting 用于检测递归类型
+func generate(t reflect.Type, visiting map[reflect.Type]bool) (*Schema, error) {
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		nullable = true
+	}
+	s, err := generateType(t, visiting)
+	if err != nil {
+		return nil, err
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Interface:
+		nullable = true
+	}
+	s.nullable = nullable
+	return s, nil
+}
+
+// generateType 生成非指针类型的 Schema
+func generateType(t reflect.Type, visiting map[reflect.Type]bool) (*Schema, error) {
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}, nil
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}, nil
+	case reflect.Interface:
+		return &Schema{}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string"}, nil
+		}
+		items, err := generate(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, errors.NewCodeErrorF(http.StatusBadRequest, "schema: unsupported map key type %s", t.Key())
+		}
+		values, err := generate(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		if visiting[t] {
+			return nil, errors.NewCodeErrorF(http.StatusBadRequest, "schema: recursive type %s is not supported", t)
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		if err := addFields(s, t, visiting); err != nil {
+			return nil, err
+		}
+		return s, nil
+	default:
+		return nil, errors.NewCodeErrorF(http.StatusBadRequest, "schema: unsupported type %s", t)
+	}
+}
+
+// addFields 将结构体字段添加到 Schema, 匿名嵌入的结构体字段会被展开
+func addFields(s *Schema, t reflect.Type, visiting map[reflect.Type]bool) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, skip := fieldName(field)
+		if skip {
+			continue
+		}
+
+		ft := field.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if field.Anonymous && ft.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			if err := addFields(s, ft, visiting); err != nil {
+				return err
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		prop, err := generate(field.Type, visiting)
+		if err != nil {
+			return err
+		}
+		prop.Description = field.Tag.Get("description")
+		required, err := applyTag(prop, field.Tag.Get("jsonschema"))
+		if err != nil {
+			return errors.NewCodeErrorF(http.StatusBadRequest, "schema: field %s: %v", field.Name, err)
+		}
+		s.Properties[name] = prop
+		if required {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return nil
+}
+
+// fieldName 返回字段的JSON名称, 未导出或 json:"-" 的字段返回 skip
+func fieldName(field reflect.StructField) (name string, skip bool) {
+	if !field.IsExported() && !field.Anonymous {
+		return "", true
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", true
+	}
+	name, _, _ = strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	return name, false
+}
+
+// applyTag 解析 jsonschema 标签, 返回字段是否必填
+func applyTag(s *Schema, tag string) (required bool, err error) {
+	if tag == "" {
+		return false, nil
+	}
+	for _, item := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(item), "=")
+		switch key {
+		case "required":
+			required = true
+		case "enum":
+			for _, v := range strings.Split(value, "|") {
+				enum, err := parseEnum(s.Type, v)
+				if err != nil {
+					return false, err
+				}
+				s.Enum = append(s.Enum, enum)
+			}
+		case "minimum", "maximum":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return false, errors.NewCodeErrorF(http.StatusBadRequest, "invalid %s %q", key, value)
+			}
+			if key == "minimum" {
+				s.Minimum = &n
+			} else {
+				s.Maximum = &n
+			}
+		case "format":
+			s.Format = value
+		case "":
+		default:
+			return false, errors.NewCodeErrorF(http.StatusBadRequest, "unknown jsonschema tag %q", key)
+		}
+	}
+	return required, nil
+}
+
+// parseEnum 按字段类型解析枚举值
+func parseEnum(tp, value string) (any, error) {
+	switch tp {
+	case "integer":
+		return strconv.ParseInt(value, 10, 64)
+	case "number":
+		return strconv.ParseFloat(value, 64)
+	case "boolean":
+		return strconv.ParseBool(value)
+	default:
+		return value, nil
+	}
+}
+
+// Validate 校验JSON数据是否符合 Schema
+func (s *Schema) Validate(data []byte) error {
+	var value any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return errors.NewCodeErrorF(http.StatusBadRequest, "invalid json: %v", err)
+	}
+	if decoder.More() {
+		return errors.NewCodeError(http.StatusBadRequest, "invalid json: unexpected data after top-level value")
+	}
+	return s.validate(value, "$")
+}
+
+// validate 递归校验值, path 为出错位置
+func (s *Schema) validate(value any, path string) error {
+	if value == nil {
+		// 未指定类型或可为空的类型允许 null, 其余类型解码后会得到零值, 视为类型错误
+		if s.Type == "" || s.nullable {
+			return nil
+		}
+		return errors.NewCodeErrorF(http.StatusBadRequest, "%s must be %s, got null", path, s.Type)
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return typeError(path, s.Type, value)
+		}
+		for _, name := range s.Required {
+			if v, ok := obj[name]; !ok || v == nil {
+				return errors.NewCodeErrorF(http.StatusBadRequest, "%s.%s is required", path, name)
+			}
+		}
+		for name, v := range obj {
+			prop := s.Properties[name]
+			if prop == nil {
+				prop = s.AdditionalProperties
+			}
+			if prop == nil {
+				continue
+			}
+			if err := prop.validate(v, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			return typeError(path, s.Type, value)
+		}
+		if s.Items != nil {
+			for i, v := range arr {
+				if err := s.Items.validate(v, path+"["+strconv.Itoa(i)+"]"); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return typeError(path, s.Type, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return typeError(path, s.Type, value)
+		}
+	case "integer", "number":
+		n, ok := value.(json.Number)
+		if !ok {
+			return typeError(path, s.Type, value)
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return typeError(path, s.Type, value)
+		}
+		if s.Type == "integer" {
+			if _, err := n.Int64(); err != nil {
+				return typeError(path, s.Type, value)
+			}
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			return errors.NewCodeErrorF(http.StatusBadRequest, "%s must be >= %v", path, *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			return errors.NewCodeErrorF(http.StatusBadRequest, "%s must be <= %v", path, *s.Maximum)
+		}
+	}
+
+	if len(s.Enum) > 0 && !s.inEnum(value) {
+		return errors.NewCodeErrorF(http.StatusBadRequest, "%s must be one of %v", path, s.Enum)
+	}
+	return nil
+}
+
+// inEnum 判断值是否为枚举值之一
+func (s *Schema) inEnum(value any) bool {
+	for _, enum := range s.Enum {
+		switch v := value.(type) {
+		case json.Number:
+			if f, err := v.Float64(); err == nil {
+				switch e := enum.(type) {
+				case int64:
+					if float64(e) == f {
+						return true
+					}
+				case float64:
+					if e == f {
+						return true
+					}
+				}
+			}
+		default:
+			if enum == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// typeError 类型不匹配错误
+func typeError(path, expected string, value any) error {
+	actual := "unknown"
+	switch value.(type) {
+	case map[string]any:
+		actual = "object"
+	case []any:
+		actual = "array"
+	case string:
+		actual = "string"
+	case bool:
+		actual = "boolean"
+	case json.Number:
+		actual = "number"
+	}
+	return errors.NewCodeErrorF(http.StatusBadRequest, "%s must be %s, got %s", path, expected, actual)
+}
+
+// Decode 根据 T 的 Schema 校验工具调用参数, 通过后解码为 T
+// 参数为空字符串时视为 "{}"
+func Decode[T any](arguments string) (T, error) {
+	var v T
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+	s, err := decodeSchema[T]()
+	if err != nil {
+		return v, err
+	}
+	if err := s.Validate([]byte(arguments)); err != nil {
+		return v, err
+	}
+	if err := json.Unmarshal([]byte(arguments), &v); err != nil {
+		return v, errors.NewCodeErrorF(http.StatusBadRequest, "failed to decode arguments: %v", err)
+	}
+	return v, nil
+}
+
+// decodeSchema 返回 T 的 Schema, 按类型缓存以避免每次工具调用都通过反射重新生成
+func decodeSchema[T any]() (*Schema, error) {
+	t := reflect.TypeFor[T]()
+	if s, ok := decodeSchemas.Load(t); ok {
+		return s.(*Schema), nil
+	}
+	s, err := Generate(t)
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := decodeSchemas.LoadOrStore(t, s)
+	return actual.(*Schema), nil
+}
@@ -0,0 +1,109 @@
+package completion
+
+import "github.com/miajio/dpsk/chat"
+
+// CompletionOption 配置项
+type CompletionOption func(*CompletionRequest)
+
+// NewCompletionRequest 创建FIM补全请求
+func NewCompletionRequest(options ...CompletionOption) (*CompletionRequest, error) {
+	req := &CompletionRequest{}
+	for _, option := range options {
+		option(req)
+	}
+
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// WithModel 设置模型
+func WithModel(model string) CompletionOption {
+	return func(req *CompletionRequest) {
+		req.Model = model
+	}
+}
+
+// WithPrompt 设置前缀内容
+func WithPrompt(prompt string) CompletionOption {
+	return func(req *CompletionRequest) {
+		req.Prompt = prompt
+	}
+}
+
+// WithSuffix 设置后缀内容
+func WithSuffix(suffix string) CompletionOption {
+	return func(req *CompletionRequest) {
+		req.Suffix = suffix
+	}
+}
+
+// WithEcho 设置是否回显 prompt
+func WithEcho(echo bool) CompletionOption {
+	return func(req *CompletionRequest) {
+		req.Echo = echo
+	}
+}
+
+// WithLogprobs 设置返回的候选token对数概率数量(0-20)
+func WithLogprobs(logprobs int) CompletionOption {
+	return func(req *CompletionRequest) {
+		req.Logprobs = logprobs
+	}
+}
+
+// WithMaxTokens 设置最大token数
+func WithMaxTokens(maxTokens int) CompletionOption {
+	return func(req *CompletionRequest) {
+		req.MaxTokens = maxTokens
+	}
+}
+
+// WithStop 设置停止符
+func WithStop(stopVal ...string) CompletionOption {
+	return func(req *CompletionRequest) {
+		if stopVal != nil {
+			if len(stopVal) == 1 {
+				req.Stop = stopVal[0]
+			} else {
+				req.Stop = stopVal
+			}
+		}
+	}
+}
+
+// WithStream 设置流式响应
+func WithStream(stream bool) CompletionOption {
+	return func(req *CompletionRequest) {
+		req.Stream = stream
+	}
+}
+
+// WithStreamOptions 设置流式响应的选项
+func WithStreamOptions(includeUsage bool) CompletionOption {
+	return func(req *CompletionRequest) {
+		req.StreamOptions = &chat.StreamOptions{
+			IncludeUsage: includeUsage,
+		}
+	}
+}
+
+// WithTemperature 设置采样温度(0-2)
+func WithTemperature(temperature float64) CompletionOption {
+	return func(req *CompletionRequest) {
+		if temperature > 0 && 2 > temperature {
+			req.Temperature = temperature
+		}
+	}
+}
+
+// WithTopP 设置核心采样(0-1)
+func WithTopP(topP float64) CompletionOption {
+	return func(req *CompletionRequest) {
+		if topP > 0 && 1 > topP {
+			req.TopP = topP
+		}
+	}
+}
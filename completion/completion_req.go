@@ -0,0 +1,49 @@
+// Package completion DeepSeek FIM(fill-in-the-middle)补全接口(beta)的请求与响应结构
+// 给定前缀 prompt 与后缀 suffix, 由模型补全中间内容, 适用于代码补全等场景
+package completion
+
+import (
+	"net/http"
+
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/errors"
+)
+
+const (
+	MaxTokensLimit   = 4096 // FIM 补全的最大输出token数
+	MaxLogprobsLimit = 20   // logprobs 的最大值
+)
+
+// CompletionRequest FIM补全请求
+type CompletionRequest struct {
+	Model            string              `json:"model"`                       // 模型名称, 目前仅 deepseek-chat 支持
+	Prompt           string              `json:"prompt"`                      // 前缀内容
+	Suffix           string              `json:"suffix,omitempty"`            // 后缀内容
+	Echo             bool                `json:"echo,omitempty"`              // 是否在输出中回显 prompt
+	FrequencyPenalty float64             `json:"frequency_penalty,omitempty"` // 频率惩罚(-2.0到2.0)
+	Logprobs         int                 `json:"logprobs,omitempty"`          // 返回概率最高的N个候选token的对数概率(0-20)
+	MaxTokens        int                 `json:"max_tokens,omitempty"`        // 最大输出token数(不超过4096)
+	PresencePenalty  float64             `json:"presence_penalty,omitempty"`  // 存在惩罚(-2.0到2.0)
+	Stop             any                 `json:"stop,omitempty"`              // 停止词(string或[]string)
+	Stream           bool                `json:"stream,omitempty"`            // 是否使用流式传输
+	StreamOptions    *chat.StreamOptions `json:"stream_options,omitempty"`    // 流式选项
+	Temperature      float64             `json:"temperature,omitempty"`       // 采样温度(0-2)
+	TopP             float64             `json:"top_p,omitempty"`             // 核心采样(0-1)
+}
+
+// Validate 验证请求参数
+func (cr *CompletionRequest) Validate() error {
+	if cr.Model == "" {
+		return errors.NewCodeError(http.StatusBadRequest, "model is required")
+	}
+	if cr.Prompt == "" {
+		return errors.NewCodeError(http.StatusBadRequest, "prompt is required")
+	}
+	if cr.MaxTokens < 0 || cr.MaxTokens > MaxTokensLimit {
+		return errors.NewCodeErrorF(http.StatusBadRequest, "max_tokens must be between 0 and %d", MaxTokensLimit)
+	}
+	if cr.Logprobs < 0 || cr.Logprobs > MaxLogprobsLimit {
+		return errors.NewCodeErrorF(http.StatusBadRequest, "logprobs must be between 0 and %d", MaxLogprobsLimit)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package completion
+
+import "github.com/miajio/dpsk/chat"
+
+// Logprobs 对数概率信息
+type Logprobs struct {
+	TextOffset    []int                `json:"text_offset"`    // 每个token在文本中的偏移
+	TokenLogprobs []float64            `json:"token_logprobs"` // 每个token的对数概率
+	Tokens        []string             `json:"tokens"`         // 输出的token
+	TopLogprobs   []map[string]float64 `json:"top_logprobs"`   // 每个位置概率最高的候选token及其对数概率
+}
+
+// Choice 补全选项
+type Choice struct {
+	FinishReason string    `json:"finish_reason"` // 完成原因 [stop, length, content_filter, insufficient_system_resource]
+	Index        int       `json:"index"`         // 选项序号
+	Logprobs     *Logprobs `json:"logprobs"`      // 对数概率信息
+	Text         string    `json:"text"`          // 补全内容, 流式响应中为增量内容
+}
+
+// CompletionResponse FIM补全响应, 流式响应的每个响应块结构相同
+type CompletionResponse struct {
+	ID                string     `json:"id"`                 // 请求ID
+	Choices           []Choice   `json:"choices"`            // 补全选项
+	Created           int        `json:"created"`            // 创建时间戳
+	Model             string     `json:"model"`              // 模型名称
+	SystemFingerprint string     `json:"system_fingerprint"` // 系统指纹
+	Object            string     `json:"object"`             // 对象类型
+	Usage             chat.Usage `json:"usage"`              // 消耗的用量
+}
+
+// Text 返回第一个选项的补全内容
+func (r *CompletionResponse) Text() string {
+	if len(r.Choices) == 0 {
+		return ""
+	}
+	return r.Choices[0].Text
+}
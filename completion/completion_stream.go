@@ -0,0 +1,78 @@
+package completion
+
+import (
+	"sort"
+	"strings"
+)
+
+// StreamAccumulator 流式响应累加器, 按 choice 序号合并增量补全内容
+type StreamAccumulator struct {
+	resp    CompletionResponse
+	choices map[int]*Choice
+	texts   map[int]*strings.Builder
+}
+
+// NewStreamAccumulator 创建流式响应累加器
+func NewStreamAccumulator() *StreamAccumulator {
+	return &StreamAccumulator{choices: make(map[int]*Choice), texts: make(map[int]*strings.Builder)}
+}
+
+// Add 添加一个流式响应块
+// choice 序号来自服务端, 按序号作为 key 合并, 不按序号分配切片
+func (a *StreamAccumulator) Add(chunk CompletionResponse) {
+	if chunk.ID != "" {
+		a.resp.ID = chunk.ID
+	}
+	if chunk.Created != 0 {
+		a.resp.Created = chunk.Created
+	}
+	if chunk.Model != "" {
+		a.resp.Model = chunk.Model
+	}
+	if chunk.SystemFingerprint != "" {
+		a.resp.SystemFingerprint = chunk.SystemFingerprint
+	}
+	if chunk.Usage.TotalTokens > 0 {
+		a.resp.Usage = chunk.Usage
+	}
+	for _, c := range chunk.Choices {
+		choice, ok := a.choices[c.Index]
+		if !ok {
+			choice = &Choice{Index: c.Index}
+			a.choices[c.Index] = choice
+			a.texts[c.Index] = &strings.Builder{}
+		}
+		if c.FinishReason != "" {
+			choice.FinishReason = c.FinishReason
+		}
+		if c.Logprobs != nil {
+			if choice.Logprobs == nil {
+				choice.Logprobs = &Logprobs{}
+			}
+			choice.Logprobs.TextOffset = append(choice.Logprobs.TextOffset, c.Logprobs.TextOffset...)
+			choice.Logprobs.TokenLogprobs = append(choice.Logprobs.TokenLogprobs, c.Logprobs.TokenLogprobs...)
+			choice.Logprobs.Tokens = append(choice.Logprobs.Tokens, c.Logprobs.Tokens...)
+			choice.Logprobs.TopLogprobs = append(choice.Logprobs.TopLogprobs, c.Logprobs.TopLogprobs...)
+		}
+		a.texts[c.Index].WriteString(c.Text)
+	}
+}
+
+// Response 返回合并后的完整响应, 选项按序号排列
+func (a *StreamAccumulator) Response() *CompletionResponse {
+	resp := a.resp
+	resp.Object = "text_completion"
+
+	indexes := make([]int, 0, len(a.choices))
+	for index := range a.choices {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	resp.Choices = make([]Choice, 0, len(indexes))
+	for _, index := range indexes {
+		choice := *a.choices[index]
+		choice.Text = a.texts[index].String()
+		resp.Choices = append(resp.Choices, choice)
+	}
+	return &resp
+}
@@ -0,0 +1,112 @@
+package demo_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/chat/agent"
+	"github.com/miajio/dpsk/engine"
+	"github.com/miajio/dpsk/model"
+)
+
+func TestAgentRun(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chat.ChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if calls.Add(1) == 1 {
+			if len(req.Tools) != 1 {
+				t.Errorf("expected registered tools to be sent, got %d", len(req.Tools))
+			}
+			w.Write([]byte(`{"id":"1","choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","content":"","tool_calls":[` +
+				`{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"北京\"}"}},` +
+				`{"id":"call_2","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"上海\"}"}}]}}],"usage":{"total_tokens":10}}`))
+			return
+		}
+		last := req.Messages[len(req.Messages)-1]
+		if len(req.Messages) != 4 || last.Role != "tool" || last.ToolCallId != "call_2" || last.Content != "上海: 晴" {
+			t.Errorf("unexpected messages: %+v", req.Messages)
+		}
+		w.Write([]byte(`{"id":"2","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"北京和上海都是晴天"}}],"usage":{"total_tokens":20}}`))
+	}))
+	defer server.Close()
+
+	a := agent.New(agent.WithMaxSteps(3))
+	err := a.RegisterFunc("get_weather", "查询天气", map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"city": map[string]any{"type": "string"}},
+	}, func(ctx context.Context, arguments string) (string, error) {
+		var args struct {
+			City string `json:"city"`
+		}
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			return "", err
+		}
+		return args.City + ": 晴", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, _ := engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL))
+	chatReq, _ := chat.NewChatRequest(chat.WithModel("deepseek-chat"), chat.WithMessages(chat.Message{Role: "user", Content: "北京和上海天气如何?"}))
+
+	result, err := a.Run(context.Background(), client, chatReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Content() != "北京和上海都是晴天" || len(result.Steps) != 2 || result.Usage.TotalTokens != 30 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if len(result.Steps[0].ToolResults) != 2 || result.Steps[0].ToolResults[0].Output != "北京: 晴" {
+		t.Fatalf("unexpected tool results: %+v", result.Steps[0].ToolResults)
+	}
+	if len(chatReq.Messages) != 1 || len(result.Messages) != 5 {
+		t.Fatalf("request should not be modified: %d, transcript: %d", len(chatReq.Messages), len(result.Messages))
+	}
+}
+
+func TestAgentRunReasoner(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chat.ChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		for _, msg := range req.Messages {
+			if msg.ReasoningContent != "" {
+				t.Errorf("reasoning content should not be sent back: %+v", msg)
+			}
+		}
+		if calls.Add(1) == 1 {
+			w.Write([]byte(`{"id":"1","choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","content":"","reasoning_content":"需要查天气","tool_calls":[` +
+				`{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{}"}}]}}]}`))
+			return
+		}
+		w.Write([]byte(`{"id":"2","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"晴天","reasoning_content":"工具返回晴"}}]}`))
+	}))
+	defer server.Close()
+
+	a := agent.New()
+	if err := a.RegisterFunc("get_weather", "查询天气", map[string]any{"type": "object"}, func(ctx context.Context, arguments string) (string, error) {
+		return "晴", nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	client, _ := engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL))
+	chatReq, _ := chat.NewChatRequest(chat.WithModel(model.DeepSeekReasoner), chat.WithMessages(chat.Message{Role: "user", Content: "天气如何?"}))
+	result, err := a.Run(context.Background(), client, chatReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 对话记录可直接用于下一轮请求
+	history := append(result.Messages, chat.Message{Role: "user", Content: "明天呢?"})
+	next, _ := chat.NewChatRequest(chat.WithModel(model.DeepSeekReasoner), chat.WithMessages(history...))
+	if err := next.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
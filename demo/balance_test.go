@@ -0,0 +1,93 @@
+package demo_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miajio/dpsk/engine"
+	"github.com/miajio/dpsk/model"
+)
+
+func TestBalanceDecimal(t *testing.T) {
+	var balance model.Balance
+	if err := json.Unmarshal([]byte(`{"is_available":false,"balance_infos":[{"currency":"CNY","total_balance":"110.10","granted_balance":"10.00","topped_up_balance":"100.10"}]}`), &balance); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(balance)
+	if !strings.Contains(string(data), `"is_available":false`) {
+		t.Fatalf("is_available should be kept: %s", data)
+	}
+
+	total, err := balance.Total(model.CurrencyCNY)
+	if err != nil || total.String() != "110.10" {
+		t.Fatalf("unexpected total: %v %v", total, err)
+	}
+	if _, err := balance.Total(model.CurrencyUSD); err == nil {
+		t.Fatal("expected error for missing currency")
+	}
+
+	before := model.Balance{BalanceInfos: []model.BalanceInfo{{Currency: "CNY", TotalBalance: "110.30", GrantedBalance: "10.00", ToppedUpBalance: "100.30"}}}
+	spent, err := before.Sub(&balance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 0.3 - 0.1 在浮点运算下不等于 0.2
+	if info, _ := spent.Info(model.CurrencyCNY); info.TotalBalance != "0.20" || info.GrantedBalance != "0.00" {
+		t.Fatalf("unexpected diff: %+v", info)
+	}
+	cny, _ := before.Info(model.CurrencyCNY)
+	if cmp, err := cny.Cmp(balance.BalanceInfos[0]); err != nil || cmp != 1 {
+		t.Fatalf("unexpected cmp: %d %v", cmp, err)
+	}
+}
+
+func TestBalanceWatcher(t *testing.T) {
+	balances := []string{"10.00", "4.00", "4.00", "0.50", "8.00"}
+	var polls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(polls.Add(1)) - 1
+		if i >= len(balances) {
+			i = len(balances) - 1
+		}
+		fmt.Fprintf(w, `{"is_available":true,"balance_infos":[{"currency":"CNY","total_balance":"%s"}]}`, balances[i])
+	}))
+	defer server.Close()
+
+	client, _ := engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL))
+	var callbacks atomic.Int32
+	watcher := client.WatchBalance(context.Background(),
+		engine.WithWatchInterval(5*time.Millisecond),
+		engine.WithThresholds(model.MustDecimal("5"), model.MustDecimal("1")),
+		engine.WithBalanceCallback(func(engine.BalanceEvent) { callbacks.Add(1) }),
+	)
+
+	var got []string
+	timeout := time.After(2 * time.Second)
+	for len(got) < 4 {
+		select {
+		case event := <-watcher.Events():
+			got = append(got, fmt.Sprintf("%s:%s:%v", event.Threshold, event.Balance, event.Below))
+		case <-timeout:
+			t.Fatalf("timed out, got %v", got)
+		}
+	}
+	watcher.Stop()
+
+	want := []string{"5.00:4.00:true", "1.00:0.50:true", "5.00:8.00:false", "1.00:8.00:false"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("unexpected events: %v", got)
+	}
+	if callbacks.Load() != 4 || watcher.Latest() == nil {
+		t.Fatalf("unexpected callbacks %d", callbacks.Load())
+	}
+	if _, ok := <-watcher.Events(); ok {
+		t.Fatal("events channel should be closed after Stop")
+	}
+}
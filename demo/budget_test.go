@@ -0,0 +1,193 @@
+package demo_test
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/engine"
+	"github.com/miajio/dpsk/errors"
+	"github.com/miajio/dpsk/model"
+	"github.com/miajio/dpsk/pricing"
+)
+
+func TestBudget(t *testing.T) {
+	var chats atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/user/balance" {
+			w.Write([]byte(`{"is_available":true,"balance_infos":[{"currency":"CNY","total_balance":"3.00"}]}`))
+			return
+		}
+		chats.Add(1)
+		w.Write([]byte(`{"id":"1","choices":[{"index":0,"message":{"role":"assistant","content":"你好"}}],"usage":{"prompt_tokens":1000000,"prompt_cache_miss_tokens":1000000,"completion_tokens":0,"total_tokens":1000000}}`))
+	}))
+	defer server.Close()
+
+	// 每次请求实际花费 2 元
+	table := pricing.NewTable(pricing.ModelPricing{
+		Model:  "deepseek-chat",
+		Prices: map[string]pricing.Price{pricing.CNY: {InputCacheMiss: model.MustDecimal("2")}},
+	})
+	chatReq, _ := chat.NewChatRequest(chat.WithModel("deepseek-chat"), chat.WithMessages(chat.Message{Role: "user", Content: "你好"}))
+
+	// 总预算 5 元: 前三次请求放行, 第四次超出
+	client, _ := engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL),
+		engine.WithBudget(engine.Budget{Total: model.MustDecimal("5"), Table: table}))
+	for i := 0; i < 3; i++ {
+		if _, err := client.Chat(context.Background(), chatReq); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := client.Chat(context.Background(), chatReq)
+	var budgetErr *engine.ErrBudgetExceeded
+	if !stderrors.As(err, &budgetErr) || budgetErr.Scope != engine.BudgetScopeTotal || budgetErr.Spent.String() != "6.00" {
+		t.Fatalf("expected total budget error, got %v", err)
+	}
+	if codeErr := errors.ReadCodeError(err); codeErr == nil || codeErr.Code != http.StatusPaymentRequired {
+		t.Fatalf("expected 402 code error, got %v", codeErr)
+	}
+	if n := chats.Load(); n != 3 {
+		t.Fatalf("expected 3 requests to reach the server, got %d", n)
+	}
+
+	// 标签预算与余额: 余额 3 元, 标签 a 预算 2 元
+	chats.Store(0)
+	client, _ = engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL),
+		engine.WithBudget(engine.Budget{
+			TagLimits:       map[string]model.Decimal{"a": model.MustDecimal("2")},
+			RefreshInterval: time.Hour,
+			Table:           table,
+		}))
+	ctxA := engine.ContextWithTag(context.Background(), "a")
+	if _, err := client.Chat(ctxA, chatReq); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Chat(ctxA, chatReq); !stderrors.As(err, &budgetErr) || budgetErr.Scope != engine.BudgetScopeTag || budgetErr.Tag != "a" {
+		t.Fatalf("expected tag budget error, got %v", err)
+	}
+	// 估算剩余余额 1 元时仍放行, 余额耗尽后拒绝
+	if _, err := client.Chat(context.Background(), chatReq); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Chat(context.Background(), chatReq); !stderrors.As(err, &budgetErr) || budgetErr.Scope != engine.BudgetScopeBalance {
+		t.Fatalf("expected balance budget error, got %v", err)
+	}
+	if n := chats.Load(); n != 2 {
+		t.Fatalf("expected 2 requests to reach the server, got %d", n)
+	}
+}
+
+func TestBudgetBalanceRefresh(t *testing.T) {
+	var balances atomic.Int32
+	balanceGate := make(chan struct{})
+	hold := make(chan struct{})
+	var holding atomic.Bool
+	started := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/user/balance" {
+			balances.Add(1)
+			<-balanceGate
+			w.Write([]byte(`{"is_available":true,"balance_infos":[{"currency":"CNY","total_balance":"3.00"}]}`))
+			return
+		}
+		if holding.Load() {
+			started <- struct{}{}
+			<-hold
+		}
+		w.Write([]byte(`{"id":"1","choices":[{"index":0,"message":{"role":"assistant","content":"你好"}}],"usage":{"prompt_tokens":1,"prompt_cache_miss_tokens":1,"completion_tokens":0,"total_tokens":1}}`))
+	}))
+	defer server.Close()
+
+	// 每次请求按 MaxTokens 估算 2 元, 实际花费 0 元
+	table := pricing.NewTable(pricing.ModelPricing{
+		Model:  "deepseek-chat",
+		Prices: map[string]pricing.Price{pricing.CNY: {Output: model.MustDecimal("2000")}},
+	})
+	chatReq, _ := chat.NewChatRequest(chat.WithModel("deepseek-chat"), chat.WithMessages(chat.Message{Role: "user", Content: "你好"}), chat.WithMaxTokens(1000))
+
+	// 并发请求只获取一次余额
+	client, _ := engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL),
+		engine.WithBudget(engine.Budget{RefreshInterval: time.Hour, Table: table}))
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.Chat(context.Background(), chatReq)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(balanceGate)
+	wg.Wait()
+	if n := balances.Load(); n != 1 {
+		t.Fatalf("expected 1 balance request, got %d", n)
+	}
+
+	// 每次请求都刷新余额, 刷新不会清除进行中请求的预占费用
+	client, _ = engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL),
+		engine.WithBudget(engine.Budget{RefreshInterval: time.Nanosecond, Table: table}))
+	holding.Store(true)
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.Chat(context.Background(), chatReq)
+		done <- err
+	}()
+	<-started
+	holding.Store(false)
+	var budgetErr *engine.ErrBudgetExceeded
+	if _, err := client.Chat(context.Background(), chatReq); !stderrors.As(err, &budgetErr) || budgetErr.Scope != engine.BudgetScopeBalance {
+		t.Fatalf("expected balance budget error while a request is in flight, got %v", err)
+	}
+	close(hold)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	// 进行中的请求以实际花费结算后释放预占
+	if _, err := client.Chat(context.Background(), chatReq); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBudgetStreamWithoutUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/user/balance" {
+			w.Write([]byte(`{"is_available":true,"balance_infos":[{"currency":"CNY","total_balance":"3.00"}]}`))
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"你好\"}}]}\n\ndata: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	// 每次请求按 MaxTokens 估算 1 元, 每次请求都刷新余额
+	table := pricing.NewTable(pricing.ModelPricing{
+		Model:  "deepseek-chat",
+		Prices: map[string]pricing.Price{pricing.CNY: {Output: model.MustDecimal("1000")}},
+	})
+	client, _ := engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL),
+		engine.WithBudget(engine.Budget{RefreshInterval: time.Nanosecond, Table: table}))
+	req, _ := chat.NewChatRequest(chat.WithModel("deepseek-chat"), chat.WithMessages(chat.Message{Role: "user", Content: "你好"}),
+		chat.WithMaxTokens(1000), chat.WithStream(true))
+
+	// 未返回用量的流按估算费用结算并释放预占, 刷新后的余额已包含实际花费, 不会重复扣减
+	for i := 0; i < 5; i++ {
+		if i%2 == 0 {
+			if _, err := client.ChatStreamCollect(context.Background(), req); err != nil {
+				t.Fatalf("stream %d: %v", i, err)
+			}
+			continue
+		}
+		// 提前关闭的流同样结算
+		stream, err := client.ChatStream(context.Background(), req)
+		if err != nil {
+			t.Fatalf("stream %d: %v", i, err)
+		}
+		stream.Close()
+	}
+}
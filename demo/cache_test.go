@@ -0,0 +1,148 @@
+package demo_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miajio/dpsk/cache"
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/dpsktest"
+	"github.com/miajio/dpsk/engine"
+)
+
+func TestResponseCache(t *testing.T) {
+	server := dpsktest.NewServer(t)
+	server.Enqueue(dpsktest.Text("你好,小明"))
+	client := server.Client(engine.WithCache(cache.NewMemory(10, time.Minute)))
+	ctx := context.Background()
+
+	req, _ := chat.NewChatRequest(chat.WithModel("deepseek-chat"), chat.WithMessages(chat.Message{Role: "user", Content: "你好,我叫小明"}))
+	for range 2 {
+		resp, err := client.Chat(ctx, req)
+		if err != nil || resp.Content() != "你好,小明" {
+			t.Fatalf("unexpected response: %v %v", resp, err)
+		}
+	}
+	server.AssertRequestCount(t, dpsktest.PathChat, 1)
+
+	// 相同请求的流式调用回放缓存
+	streamReq, _ := chat.NewChatRequest(chat.WithModel("deepseek-chat"), chat.WithMessages(chat.Message{Role: "user", Content: "你好,我叫小明"}),
+		chat.WithStream(true), chat.WithStreamOptions(true))
+	resp, err := client.ChatStreamCollect(ctx, streamReq)
+	if err != nil || resp.Content() != "你好,小明" || resp.Usage.TotalTokens == 0 {
+		t.Fatalf("unexpected cached stream: %+v %v", resp, err)
+	}
+	server.AssertRequestCount(t, dpsktest.PathChat, 1)
+
+	// 跳过与刷新缓存; 脚本耗尽后模拟服务回显最后一条消息
+	if resp, err = client.Chat(engine.ContextWithCacheMode(ctx, engine.CacheBypass), req); err != nil || resp.Content() != "你好,我叫小明" {
+		t.Fatalf("unexpected bypass response: %v %v", resp, err)
+	}
+	server.Enqueue(dpsktest.Text("重新生成"))
+	if resp, err = client.Chat(engine.ContextWithCacheMode(ctx, engine.CacheRefresh), req); err != nil || resp.Content() != "重新生成" {
+		t.Fatalf("unexpected refresh response: %v %v", resp, err)
+	}
+	if resp, err = client.Chat(ctx, req); err != nil || resp.Content() != "重新生成" {
+		t.Fatalf("unexpected refreshed cache: %v %v", resp, err)
+	}
+	server.AssertRequestCount(t, dpsktest.PathChat, 3)
+
+	// 不同的采样参数使用不同的缓存
+	other, _ := chat.NewChatRequest(chat.WithModel("deepseek-chat"), chat.WithMessages(chat.Message{Role: "user", Content: "你好,我叫小明"}), chat.WithTemperature(0.5))
+	if _, err := client.Chat(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	server.AssertRequestCount(t, dpsktest.PathChat, 4)
+}
+
+func TestDiskCache(t *testing.T) {
+	dir := t.TempDir()
+	store, err := cache.NewDisk(dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := dpsktest.NewServer(t)
+	server.Enqueue(dpsktest.ToolCalls(dpsktest.ToolCall("call_1", "get_weather", `{"city":"北京"}`)))
+	client := server.Client(engine.WithCache(store))
+
+	// 未开启 include_usage 的流没有用量, 不写入缓存
+	req, _ := chat.NewChatRequest(chat.WithModel("deepseek-chat"), chat.WithMessages(chat.Message{Role: "user", Content: "北京天气"}), chat.WithStream(true))
+	if _, err := client.ChatStreamCollect(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("stream without usage should not be cached, got %d entries", len(entries))
+	}
+
+	server.Enqueue(dpsktest.ToolCalls(dpsktest.ToolCall("call_1", "get_weather", `{"city":"北京"}`)))
+	req, _ = chat.NewChatRequest(chat.WithModel("deepseek-chat"), chat.WithMessages(chat.Message{Role: "user", Content: "北京天气"}),
+		chat.WithStream(true), chat.WithStreamOptions(true))
+	first, err := client.ChatStreamCollect(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 新的存储实例读取同一目录, 流式响应回放工具调用
+	store, _ = cache.NewDisk(dir, time.Hour)
+	client = server.Client(engine.WithCache(store))
+	second, err := client.ChatStreamCollect(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.AssertRequestCount(t, dpsktest.PathChat, 2)
+	calls := second.Choices[0].Message.ToolCalls
+	if len(calls) != 1 || calls[0].Function.Arguments != first.Choices[0].Message.ToolCalls[0].Function.Arguments ||
+		second.Choices[0].FinishReason != "tool_calls" {
+		t.Fatalf("unexpected cached tool calls: %+v", second)
+	}
+}
+
+func TestMemoryCacheEviction(t *testing.T) {
+	store := cache.NewMemory(2, 0)
+	store.Set("a", []byte("1"))
+	store.Set("b", []byte("2"))
+	store.Get("a")
+	store.Set("c", []byte("3"))
+	if _, ok, _ := store.Get("b"); ok {
+		t.Fatal("expected least recently used entry to be evicted")
+	}
+	if _, ok, _ := store.Get("a"); !ok || store.Len() != 2 {
+		t.Fatalf("unexpected cache state, len %d", store.Len())
+	}
+
+	expiring := cache.NewMemory(0, time.Millisecond)
+	expiring.Set("a", []byte("1"))
+	time.Sleep(5 * time.Millisecond)
+	if _, ok, _ := expiring.Get("a"); ok {
+		t.Fatal("expected entry to expire")
+	}
+}
+
+func TestDiskCacheKeyEscape(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "cache")
+	store, err := cache.NewDisk(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 包含路径分隔符的键不能写到缓存目录之外
+	key := "../escape"
+	if err := store.Set(key, []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "escape.json")); !os.IsNotExist(err) {
+		t.Fatalf("cache file escaped the cache directory: %v", err)
+	}
+	if value, ok, err := store.Get(key); err != nil || !ok || string(value) != "1" {
+		t.Fatalf("unexpected cached value: %q %v %v", value, ok, err)
+	}
+	if err := store.Delete(key); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("expected empty cache directory, got %d entries", len(entries))
+	}
+}
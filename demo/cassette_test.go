@@ -0,0 +1,68 @@
+package demo_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/dpsktest"
+	"github.com/miajio/dpsk/dpsktest/cassette"
+	"github.com/miajio/dpsk/engine"
+)
+
+func TestCassette(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testdata", "chat.json")
+	req, _ := chat.NewChatRequest(chat.WithModel("deepseek-chat"), chat.WithMessages(chat.Message{Role: "user", Content: "你好,我叫小明"}))
+	streamReq, _ := chat.NewChatRequest(chat.WithModel("deepseek-chat"), chat.WithMessages(chat.Message{Role: "user", Content: "流式"}),
+		chat.WithStream(true), chat.WithStreamOptions(true))
+
+	// 录制: 请求发往模拟服务
+	server := dpsktest.NewServer(t)
+	server.Enqueue(dpsktest.Text("你好,小明"), dpsktest.Text("流式回复"))
+	rec, err := cassette.New(path, cassette.WithRedactFields("content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Mode() != cassette.ModeRecord {
+		t.Fatalf("expected record mode, got %v", rec.Mode())
+	}
+	client := server.Client(engine.WithHttpClient(rec.HTTPClient()))
+	resp, err := client.Chat(context.Background(), req)
+	if err != nil || resp.Content() != "你好,小明" {
+		t.Fatalf("unexpected response: %v %v", resp, err)
+	}
+	if resp, err = client.ChatStreamCollect(context.Background(), streamReq); err != nil || resp.Content() != "流式回复" {
+		t.Fatalf("unexpected stream response: %v %v", resp, err)
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "test-key") || strings.Contains(string(data), "我叫小明") {
+		t.Fatalf("cassette is not redacted: %s", data)
+	}
+
+	// 回放: 服务已关闭, 响应全部来自 cassette
+	rec = cassette.Use(t, path, cassette.WithRedactFields("content"))
+	if rec.Mode() != cassette.ModeReplay {
+		t.Fatalf("expected replay mode, got %v", rec.Mode())
+	}
+	client = server.Client(engine.WithHttpClient(rec.HTTPClient()))
+	if resp, err = client.Chat(context.Background(), req); err != nil || resp.Content() != "你好,小明" {
+		t.Fatalf("unexpected replayed response: %v %v", resp, err)
+	}
+	if resp, err = client.ChatStreamCollect(context.Background(), streamReq); err != nil || resp.Content() != "流式回复" || resp.Usage.TotalTokens == 0 {
+		t.Fatalf("unexpected replayed stream response: %v %v", resp, err)
+	}
+	if _, err := client.Chat(context.Background(), req); err == nil {
+		t.Fatal("expected error for unmatched request")
+	}
+}
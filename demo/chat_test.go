@@ -9,23 +9,16 @@ import (
 	"time"
 
 	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/dpsktest"
 	"github.com/miajio/dpsk/engine"
 )
 
-const (
-	API_KEY = "YOU DEEPSEEK API KEY"
-)
-
 func TestGetModel(t *testing.T) {
-	client, err := engine.NewClient(engine.WithApiKey(API_KEY), engine.WithTimeout(30*time.Second))
-	if err != nil {
-		fmt.Println("failed to create client:", err)
-		return
-	}
+	server := dpsktest.NewServer(t)
+	client := server.Client(engine.WithTimeout(30 * time.Second))
 	modelList, err := client.GetModels(context.Background())
 	if err != nil {
-		fmt.Println("failed to get models:", err)
-		return
+		t.Fatal("failed to get models:", err)
 	}
 	byteModelList, _ := json.Marshal(modelList)
 	fmt.Println(string(byteModelList))
@@ -33,51 +26,39 @@ func TestGetModel(t *testing.T) {
 }
 
 func TestGetBalance(t *testing.T) {
-	client, err := engine.NewClient(engine.WithApiKey(API_KEY), engine.WithTimeout(30*time.Second))
-	if err != nil {
-		fmt.Println("failed to create client:", err)
-		return
-	}
+	server := dpsktest.NewServer(t)
+	client := server.Client(engine.WithTimeout(30 * time.Second))
 	balance, err := client.GetBalance(context.Background())
 	if err != nil {
-		fmt.Println("failed to get models:", err)
-		return
+		t.Fatal("failed to get models:", err)
 	}
 	byteBalance, _ := json.Marshal(balance)
 	fmt.Println(string(byteBalance))
 }
 
 func TestChat(t *testing.T) {
-	client, err := engine.NewClient(engine.WithApiKey(API_KEY), engine.WithTimeout(24*time.Hour))
-	if err != nil {
-		fmt.Println("failed to create client:", err)
-		return
-	}
+	server := dpsktest.NewServer(t)
+	client := server.Client(engine.WithTimeout(24 * time.Hour))
 
 	chatReq, err := chat.NewChatRequest(chat.WithModel("deepseek-chat"), chat.WithMessages([]chat.Message{
 		{Role: "system", Content: "你是一个情感ai程序"},
 		{Role: "user", Content: "你好,我叫小明"},
 	}...))
 	if err != nil {
-		fmt.Println("failed to create chat request:", err)
-		return
+		t.Fatal("failed to create chat request:", err)
 	}
 
 	res, err := client.Chat(context.Background(), chatReq)
 	if err != nil {
-		fmt.Println("failed to chat:", err)
-		return
+		t.Fatal("failed to chat:", err)
 	}
 	byteRes, _ := json.Marshal(res)
 	fmt.Println(string(byteRes))
 }
 
 func TestChatStream(t *testing.T) {
-	client, err := engine.NewClient(engine.WithApiKey(API_KEY), engine.WithTimeout(24*time.Hour))
-	if err != nil {
-		fmt.Println("failed to create client:", err)
-		return
-	}
+	server := dpsktest.NewServer(t)
+	client := server.Client(engine.WithTimeout(24 * time.Hour))
 
 	chatReq, err := chat.NewChatRequest(
 		chat.WithModel("deepseek-chat"),
@@ -89,97 +70,67 @@ func TestChatStream(t *testing.T) {
 	)
 
 	if err != nil {
-		fmt.Println("failed to create chat request:", err)
-		return
+		t.Fatal("failed to create chat request:", err)
 	}
 
-	chatStream, chatStreamErr, err := client.ChatStream(context.Background(), chatReq)
+	stream, err := client.ChatStream(context.Background(), chatReq)
 	if err != nil {
-		fmt.Println("failed to chat stream:", err)
-		return
+		t.Fatal("failed to chat stream:", err)
 	}
 
 	nextContent := ""
-
-	for {
-		select {
-		case msg, ok := <-chatStream:
-			if !ok {
-				goto NextA
-			}
-			if len(msg.Choices) > 0 {
-				delta := msg.Choices[0].Delta.Content
-				nextContent += delta
-				fmt.Print(delta)
-			}
-		case err, ok := <-chatStreamErr:
-			if ok {
-				log.Printf("Stream error: %v", err)
-			}
-			log.Println("Stream ended", err)
-			goto NextA
+	for stream.Next() {
+		msg := stream.Current()
+		if len(msg.Choices) > 0 {
+			delta := msg.Choices[0].Delta.Content
+			nextContent += delta
+			fmt.Print(delta)
 		}
 	}
-NextA:
+	if err := stream.Err(); err != nil {
+		log.Printf("Stream error: %v", err)
+	}
+	log.Println("Stream ended")
+
 	chatReq.AddMessage("assistant", nextContent)
 	chatReq.AddMessage("user", "你喜欢听歌么?我最近很emo,想听听让我开心的歌")
 
-	chatStream, chatStreamErr, err = client.ChatStream(context.Background(), chatReq)
+	stream, err = client.ChatStream(context.Background(), chatReq)
 	if err != nil {
-		fmt.Println("failed to chat stream:", err)
-		return
+		t.Fatal("failed to chat stream:", err)
 	}
 
 	nextContent = ""
-
-	for {
-		select {
-		case msg, ok := <-chatStream:
-			if !ok {
-				goto NextB
-			}
-			if len(msg.Choices) > 0 {
-				delta := msg.Choices[0].Delta.Content
-				nextContent += delta
-				fmt.Print(delta)
-			}
-		case err, ok := <-chatStreamErr:
-			if ok {
-				log.Printf("Stream error: %v", err)
-			}
-			log.Println("Stream ended", err)
-			goto NextB
+	for msg, err := range stream.All() {
+		if err != nil {
+			log.Printf("Stream error: %v", err)
+			break
+		}
+		if len(msg.Choices) > 0 {
+			delta := msg.Choices[0].Delta.Content
+			nextContent += delta
+			fmt.Print(delta)
 		}
 	}
-NextB:
+	log.Println("Stream ended")
+
 	chatReq.AddMessage("assistant", nextContent)
 	chatReq.AddMessage("user", "我是和女朋友吵架了, 她因为我在六一儿童节没有给她送礼物而不开心,我该怎么办?")
 
-	chatStream, chatStreamErr, err = client.ChatStream(context.Background(), chatReq)
+	stream, err = client.ChatStream(context.Background(), chatReq)
 	if err != nil {
-		fmt.Println("failed to chat stream:", err)
-		return
+		t.Fatal("failed to chat stream:", err)
 	}
+	defer stream.Close()
 
-	nextContent = ""
-
-	for {
-		select {
-		case msg, ok := <-chatStream:
-			if !ok {
-				return
-			}
-			if len(msg.Choices) > 0 {
-				delta := msg.Choices[0].Delta.Content
-				nextContent += delta
-				fmt.Print(delta)
-			}
-		case err, ok := <-chatStreamErr:
-			if ok {
-				log.Printf("Stream error: %v", err)
-			}
-			log.Println("Stream ended", err)
-			return
+	for stream.Next() {
+		msg := stream.Current()
+		if len(msg.Choices) > 0 {
+			fmt.Print(msg.Choices[0].Delta.Content)
 		}
 	}
+	if err := stream.Err(); err != nil {
+		log.Printf("Stream error: %v", err)
+	}
+	log.Println("Stream ended")
 }
@@ -0,0 +1,108 @@
+package demo_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miajio/dpsk/completion"
+	"github.com/miajio/dpsk/engine"
+)
+
+func TestComplete(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/beta/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		var req completion.CompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		if req.Prompt != "def fib(a):" || req.Suffix != "    return fib(a-1) + fib(a-2)" {
+			t.Errorf("unexpected request: %+v", req)
+		}
+		if !req.Stream {
+			fmt.Fprint(w, `{"id":"1","object":"text_completion","choices":[{"index":0,"text":"\n    if a <= 1:\n        return a\n","finish_reason":"stop"}],"usage":{"prompt_tokens":10,"completion_tokens":8,"total_tokens":18}}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, text := range []string{"\n    if a <= 1:", "\n        return a\n"} {
+			fmt.Fprintf(w, "data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"text\":%q}]}\n\n", text)
+		}
+		fmt.Fprint(w, "data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"text\":\"\",\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	client, _ := engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL), engine.WithBetaUrl(server.URL+"/beta"))
+	req, err := completion.NewCompletionRequest(
+		completion.WithModel("deepseek-chat"),
+		completion.WithPrompt("def fib(a):"),
+		completion.WithSuffix("    return fib(a-1) + fib(a-2)"),
+		completion.WithMaxTokens(128),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "\n    if a <= 1:\n        return a\n"
+	resp, err := client.Complete(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != want || resp.Usage.TotalTokens != 18 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+
+	req.Stream = true
+	resp, err = client.CompleteStreamCollect(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != want || resp.Choices[0].FinishReason != "stop" {
+		t.Fatalf("unexpected stream response: %+v", resp)
+	}
+
+	if _, err := completion.NewCompletionRequest(completion.WithModel("deepseek-chat"), completion.WithPrompt("x"), completion.WithMaxTokens(8192)); err == nil {
+		t.Fatal("expected max_tokens error")
+	}
+
+	// 各客户端的url配置互不影响
+	other, _ := engine.NewClient(engine.WithApiKey("test"), engine.WithBetaUrl(server.URL+"/beta"), engine.WithCompletionsUrl("/other"))
+	if _, err := other.Complete(context.Background(), &completion.CompletionRequest{Model: "deepseek-chat", Prompt: "def fib(a):", Suffix: "    return fib(a-1) + fib(a-2)"}); err == nil {
+		t.Fatal("expected 404 for custom completions url")
+	}
+	req.Stream = false
+	if _, err := client.Complete(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	// 开启模型注册表时, 不支持 FIM 的模型在发送前拒绝
+	checked, _ := engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL), engine.WithBetaUrl(server.URL+"/beta"),
+		engine.WithModelRegistry(time.Hour, engine.ParamPolicyReject))
+	if _, err := checked.Complete(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	req.Model = "deepseek-reasoner"
+	if _, err := checked.Complete(context.Background(), req); err == nil {
+		t.Fatal("expected FIM capability error")
+	}
+}
+
+func TestCompletionStreamAccumulator(t *testing.T) {
+	acc := completion.NewStreamAccumulator()
+	acc.Add(completion.CompletionResponse{ID: "1", Choices: []completion.Choice{{Index: 1, Text: "b"}, {Index: 0, Text: "a"}}})
+	// 异常的序号不会导致 panic 或按序号分配内存
+	acc.Add(completion.CompletionResponse{Choices: []completion.Choice{{Index: -1, Text: "x"}, {Index: 1 << 40, Text: "y"}}})
+	acc.Add(completion.CompletionResponse{Choices: []completion.Choice{{Index: 0, Text: "c", FinishReason: "stop"}}})
+
+	resp := acc.Response()
+	if len(resp.Choices) != 4 || resp.Text() != "x" || resp.Choices[1].Text != "ac" || resp.Choices[1].FinishReason != "stop" ||
+		resp.Choices[2].Index != 1 || resp.Choices[2].Text != "b" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
@@ -0,0 +1,139 @@
+package demo_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/chat/conversation"
+	"github.com/miajio/dpsk/engine"
+)
+
+// newEchoServer 创建回复 "收到N条消息" 的对话服务, 支持流式与非流式请求
+func newEchoServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chat.ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		reply := fmt.Sprintf("收到%d条消息", len(req.Messages))
+		if !req.Stream {
+			fmt.Fprintf(w, `{"id":"1","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":%q}}]}`, reply)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, r := range reply {
+			fmt.Fprintf(w, "data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":%q}}]}\n\n", string(r))
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+}
+
+func TestConversation(t *testing.T) {
+	server := newEchoServer(t)
+	defer server.Close()
+
+	client, _ := engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL))
+	store, err := conversation.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := []conversation.Option{
+		conversation.WithStore(store),
+		conversation.WithSystemPrompt("你是一个情感ai程序"),
+		conversation.WithRequestOptions(chat.WithModel("deepseek-chat")),
+	}
+
+	conv, err := conversation.New(context.Background(), client, "user/1", options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := conv.Send(context.Background(), "你好,我叫小明")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Choices[0].Message.Content != "收到2条消息" {
+		t.Fatalf("unexpected reply: %s", resp.Choices[0].Message.Content)
+	}
+
+	chunks := 0
+	resp, err = conv.SendStream(context.Background(), "你喜欢听歌么?", func(chunk chat.ChatResponse) error {
+		chunks++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Choices[0].Message.Content != "收到4条消息" || chunks != 6 {
+		t.Fatalf("unexpected stream reply: %s, chunks=%d", resp.Choices[0].Message.Content, chunks)
+	}
+
+	// 重新加载会话, 历史从文件恢复
+	conv, err = conversation.New(context.Background(), client, "user/1", options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := conv.History()
+	if len(history) != 4 || history[3].Role != "assistant" || history[3].Content != "收到4条消息" {
+		t.Fatalf("unexpected history: %+v", history)
+	}
+}
+
+// chatFunc 函数形式的非流式客户端
+type chatFunc func(ctx context.Context, req *chat.ChatRequest) (*chat.ChatResponse, error)
+
+func (f chatFunc) Chat(ctx context.Context, req *chat.ChatRequest) (*chat.ChatResponse, error) {
+	return f(ctx, req)
+}
+
+func TestConversationHistoryStrategy(t *testing.T) {
+	server := newEchoServer(t)
+	defer server.Close()
+	client, _ := engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL))
+
+	conv, _ := conversation.New(context.Background(), client, "keep-last",
+		conversation.WithSystemPrompt("你是一个情感ai程序"),
+		conversation.WithRequestOptions(chat.WithModel("deepseek-chat")),
+		conversation.WithHistoryStrategy(conversation.KeepLastTurns(2)),
+	)
+	var resp *chat.ChatResponse
+	for i := 0; i < 5; i++ {
+		var err error
+		if resp, err = conv.Send(context.Background(), "你好"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 系统提示词 + 上一轮(2条) + 本轮用户消息
+	if resp.Choices[0].Message.Content != "收到4条消息" || len(conv.History()) != 4 {
+		t.Fatalf("unexpected reply: %s, history=%d", resp.Choices[0].Message.Content, len(conv.History()))
+	}
+
+	summaries := 0
+	summarizer := &conversation.Summarizer{
+		Client: chatFunc(func(ctx context.Context, req *chat.ChatRequest) (*chat.ChatResponse, error) {
+			summaries++
+			return &chat.ChatResponse{Choices: []chat.Choice{{Message: chat.Message{Role: "assistant", Content: "用户叫小明"}}}}, nil
+		}),
+		Options:   []chat.ChatOption{chat.WithModel("deepseek-chat")},
+		MaxTokens: 40,
+		KeepTurns: 1,
+	}
+	conv, _ = conversation.New(context.Background(), client, "summary",
+		conversation.WithRequestOptions(chat.WithModel("deepseek-chat")),
+		conversation.WithHistoryStrategy(summarizer),
+	)
+	for i := 0; i < 4; i++ {
+		if _, err := conv.Send(context.Background(), "你好,我叫小明,今天心情不太好,想找人聊聊天"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	history := conv.History()
+	if summaries == 0 || history[0].Role != "system" || !strings.HasSuffix(history[0].Content, "用户叫小明") {
+		t.Fatalf("expected summarized history, summaries=%d history=%+v", summaries, history)
+	}
+}
@@ -1,9 +1,15 @@
 package demo_test
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
+	"github.com/miajio/dpsk/engine"
 	"github.com/miajio/dpsk/errors"
 )
 
@@ -20,3 +26,52 @@ func TestError(t *testing.T) {
 	codeErr := errors.ReadCodeError(err)
 	fmt.Println(codeErr.Code)
 }
+
+func TestAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-123")
+		w.WriteHeader(http.StatusPaymentRequired)
+		w.Write([]byte(`{"error":{"message":"Insufficient Balance","type":"unknown_error","param":null,"code":"invalid_request_error"}}`))
+	}))
+	defer server.Close()
+
+	client, _ := engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL))
+	_, err := client.GetBalance(context.Background())
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	fmt.Println(err)
+
+	apiErr := errors.ReadAPIError(err)
+	if apiErr == nil {
+		t.Fatalf("expected *APIError, got %T", err)
+	}
+	if apiErr.Message != "Insufficient Balance" || apiErr.Type != "unknown_error" || apiErr.ErrCode != "invalid_request_error" || apiErr.RequestID != "req-123" {
+		t.Fatalf("unexpected api error: %+v", apiErr)
+	}
+
+	codeErr := errors.ReadCodeError(err)
+	if codeErr == nil || codeErr.Code != http.StatusPaymentRequired {
+		t.Fatalf("unexpected code error: %+v", codeErr)
+	}
+}
+
+func TestAPIErrorHTMLBody(t *testing.T) {
+	page := "<html>\r\n<head><title>502 Bad Gateway</title></head>\n<body>" + strings.Repeat("网关错误", 200) + "</body></html>"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte(page))
+	}))
+	defer server.Close()
+
+	client, _ := engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL))
+	_, err := client.GetBalance(context.Background())
+	apiErr := errors.ReadAPIError(err)
+	if apiErr == nil || string(apiErr.Body) != page {
+		t.Fatalf("expected *APIError with the full body, got %v", err)
+	}
+	// 错误信息只保留开头的片段, 不包含控制字符
+	if len(apiErr.Message) > 210 || !strings.HasPrefix(apiErr.Message, "<html> <head>") || strings.ContainsAny(apiErr.Message, "\r\n") || !utf8.ValidString(apiErr.Message) {
+		t.Fatalf("unexpected message: %q", apiErr.Message)
+	}
+}
@@ -0,0 +1,86 @@
+package demo_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/engine"
+)
+
+type weatherReport struct {
+	City string `json:"city" jsonschema:"required"`
+	Temp int    `json:"temp" jsonschema:"required,minimum=-50,maximum=60"`
+	Unit string `json:"unit" jsonschema:"enum=celsius|fahrenheit"`
+}
+
+func TestChatJSON(t *testing.T) {
+	replies := []string{"", `{"city":"北京","temp":"25"}`, `{"city":"北京","temp":25,"unit":"celsius"}`}
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chat.ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		if req.ResponseFormat == nil || req.ResponseFormat.Type != "json_object" {
+			t.Errorf("response_format should be json_object: %+v", req.ResponseFormat)
+		}
+		if req.Messages[0].Role != "system" || !strings.Contains(req.Messages[0].Content, "JSON") {
+			t.Errorf("json instruction should be added: %+v", req.Messages[0])
+		}
+		i := int(calls.Add(1)) - 1
+		// 重试时应携带上一次的错误信息
+		if i > 0 && !strings.Contains(req.Messages[len(req.Messages)-1].Content, "无效") {
+			t.Errorf("retry should include validation error: %+v", req.Messages)
+		}
+		fmt.Fprintf(w, `{"id":"1","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":%q}}]}`, replies[i])
+	}))
+	defer server.Close()
+
+	client, _ := engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL))
+	req, _ := chat.NewChatRequest(chat.WithModel("deepseek-chat"), chat.WithMessages(chat.Message{Role: "user", Content: "北京天气怎么样"}))
+
+	report, _, err := engine.ChatJSON[weatherReport](context.Background(), client, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.City != "北京" || report.Temp != 25 || report.Unit != "celsius" {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if len(req.Messages) != 1 || req.ResponseFormat != nil {
+		t.Fatalf("request should not be modified: %+v", req)
+	}
+
+	calls.Store(0)
+	if _, _, err := engine.ChatJSON[weatherReport](context.Background(), client, req, engine.WithJSONRetries(1)); err == nil {
+		t.Fatal("expected error after retries are exhausted")
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("expected 2 attempts, got %d", n)
+	}
+
+	// JSON 输出模式只生成对象, 非对象类型在发送前拒绝
+	calls.Store(0)
+	if _, _, err := engine.ChatJSON[[]weatherReport](context.Background(), client, req); err == nil {
+		t.Fatal("expected error for non-object schema")
+	}
+	if n := calls.Load(); n != 0 {
+		t.Fatalf("expected no request for non-object schema, got %d", n)
+	}
+	// T 为指针时 null 输出同样视为无效并重新生成
+	calls.Store(0)
+	replies = []string{"null", `{"city":"上海","temp":20,"unit":"celsius"}`}
+	ptr, _, err := engine.ChatJSON[*weatherReport](context.Background(), client, req)
+	if err != nil || ptr == nil || ptr.City != "上海" {
+		t.Fatalf("unexpected report: %+v, err=%v", ptr, err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("expected 2 attempts, got %d", n)
+	}
+}
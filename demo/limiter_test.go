@@ -0,0 +1,95 @@
+package demo_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miajio/dpsk/engine"
+)
+
+func TestRateLimit(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(`{"is_available":true}`))
+	}))
+	defer server.Close()
+
+	client, _ := engine.NewClient(
+		engine.WithApiKey("test"),
+		engine.WithApiUrl(server.URL),
+		engine.WithRateLimit(engine.RateLimit{RequestsPerMinute: 5, MaxInFlight: 1}),
+	)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.GetBalance(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if maxInFlight.Load() != 1 {
+		t.Fatalf("expected at most 1 request in flight, got %d", maxInFlight.Load())
+	}
+
+	// 每分钟5次的配额已用完, 下一次请求需要等待, ctx超时后返回
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.GetBalance(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestRateLimitRetry(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"is_available":true}`))
+	}))
+	defer server.Close()
+
+	policy := engine.DefaultRetryPolicy()
+	policy.BaseDelay = 0
+	client, _ := engine.NewClient(
+		engine.WithApiKey("test"),
+		engine.WithApiUrl(server.URL),
+		engine.WithRateLimit(engine.RateLimit{RequestsPerMinute: 3}),
+		engine.WithRetry(policy),
+	)
+	if _, err := client.GetBalance(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("expected 3 attempts, got %d", n)
+	}
+
+	// 每次重试都占用一次请求配额, 3次尝试已用完每分钟3次的配额
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.GetBalance(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("expected no request after the quota is used up, got %d", n)
+	}
+}
@@ -0,0 +1,130 @@
+package demo_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/dpsktest"
+	"github.com/miajio/dpsk/engine"
+	"github.com/miajio/dpsk/errors"
+)
+
+// logEntries 解析 JSON 日志
+func logEntries(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var entries []map[string]any
+	for line := range strings.Lines(buf.String()) {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// findLog 返回第一条指定消息的日志
+func findLog(entries []map[string]any, msg string) map[string]any {
+	for _, entry := range entries {
+		if entry["msg"] == msg {
+			return entry
+		}
+	}
+	return nil
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	server := dpsktest.NewServer(t)
+	server.Enqueue(dpsktest.ServerError(), dpsktest.Text("你好"))
+	policy := engine.DefaultRetryPolicy()
+	policy.BaseDelay = 0
+	client := server.Client(engine.WithLogger(logger), engine.WithRetry(policy))
+
+	req, _ := chat.NewChatRequest(chat.WithModel("deepseek-chat"), chat.WithMessages(chat.Message{Role: "user", Content: "我的key是test-key"}))
+	if _, err := client.Chat(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "test-key") {
+		t.Fatalf("api key is not redacted: %s", buf.String())
+	}
+	entries := logEntries(t, &buf)
+	start := findLog(entries, "dpsk request start")
+	if start == nil || start["level"] != "DEBUG" || start["model"] != "deepseek-chat" || start["body"] == nil {
+		t.Fatalf("unexpected start log: %v", start)
+	}
+	finish := findLog(entries, "dpsk request finish")
+	if finish == nil || finish["level"] != "WARN" || finish["status"] != float64(500) || finish["latency"] == nil {
+		t.Fatalf("unexpected finish log: %v", finish)
+	}
+	if retry := findLog(entries, "dpsk request retry"); retry == nil || retry["attempt"] != float64(2) {
+		t.Fatalf("unexpected retry log: %v", retry)
+	}
+	if usage := findLog(entries, "dpsk usage"); usage == nil || usage["total_tokens"].(float64) == 0 {
+		t.Fatalf("unexpected usage log: %v", usage)
+	}
+
+	// 消息内容脱敏与流式读取错误
+	buf.Reset()
+	client = server.Client(engine.WithLogger(logger), engine.WithLogRedactContent(true))
+	server.Enqueue(dpsktest.Malformed("半截"))
+	streamReq, _ := chat.NewChatRequest(chat.WithModel("deepseek-chat"), chat.WithMessages(chat.Message{Role: "user", Content: "秘密内容"}), chat.WithStream(true))
+	if _, err := client.ChatStreamCollect(context.Background(), streamReq); err == nil {
+		t.Fatal("expected malformed stream error")
+	}
+	if strings.Contains(buf.String(), "秘密内容") || !strings.Contains(buf.String(), "[REDACTED]") {
+		t.Fatalf("message content is not redacted: %s", buf.String())
+	}
+	entries = logEntries(t, &buf)
+	if streamErr := findLog(entries, "dpsk stream error"); streamErr == nil || streamErr["op"] != "chat-stream" || streamErr["error"] == nil {
+		t.Fatalf("unexpected stream error log: %v", streamErr)
+	}
+}
+
+func TestLoggerStreamErrorEvent(t *testing.T) {
+	events := []string{
+		"event: error\ndata: {\"detail\":\"秘密内容\"}\n\n",
+		"data: {\"error\":{\"message\":\"Content Exists Risk\",\"type\":\"invalid_request_error\",\"code\":\"content_filter\"}}\n\n",
+	}
+	var i atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, events[i.Add(1)-1])
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	client, _ := engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL), engine.WithLogger(logger))
+	req, _ := chat.NewChatRequest(chat.WithModel("deepseek-chat"), chat.WithMessages(chat.Message{Role: "user", Content: "你好"}), chat.WithStream(true))
+
+	// 无法解析的错误事件: 错误信息与日志均不包含原始数据
+	_, err := client.ChatStreamCollect(context.Background(), req)
+	if apiErr := errors.ReadAPIError(err); apiErr == nil || !strings.Contains(string(apiErr.Body), "秘密内容") || strings.Contains(err.Error(), "秘密内容") {
+		t.Fatalf("unexpected stream error: %v", err)
+	}
+	if strings.Contains(buf.String(), "秘密内容") {
+		t.Fatalf("raw error event is logged: %s", buf.String())
+	}
+
+	// 错误格式的事件记录解析后的字段
+	buf.Reset()
+	if _, err := client.ChatStreamCollect(context.Background(), req); err == nil {
+		t.Fatal("expected stream error")
+	}
+	streamErr := findLog(logEntries(t, &buf), "dpsk stream error")
+	fields, _ := streamErr["error"].(map[string]any)
+	if fields == nil || fields["message"] != "Content Exists Risk" || fields["err_code"] != "content_filter" {
+		t.Fatalf("unexpected stream error log: %v", streamErr)
+	}
+}
@@ -0,0 +1,67 @@
+package demo_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/miajio/dpsk/engine"
+)
+
+func TestMiddleware(t *testing.T) {
+	var traceID string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceID = r.Header.Get("X-Trace-Id")
+		w.Write([]byte(`{"is_available":true}`))
+	}))
+	defer server.Close()
+
+	var ops []engine.Operation
+	record := func(next engine.Handler) engine.Handler {
+		return func(ctx context.Context, req *engine.Request) (*http.Response, error) {
+			ops = append(ops, req.Operation)
+			return next(ctx, req)
+		}
+	}
+	// 模型列表直接返回, 不发送请求
+	shortCircuit := func(next engine.Handler) engine.Handler {
+		return func(ctx context.Context, req *engine.Request) (*http.Response, error) {
+			if req.Operation != engine.OperationModels {
+				return next(ctx, req)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Status:     "200 OK",
+				Header:     make(http.Header),
+				Body:       io.NopCloser(strings.NewReader(`{"object":"list","data":[{"id":"cached"}]}`)),
+			}, nil
+		}
+	}
+
+	client, _ := engine.NewClient(
+		engine.WithApiKey("test"),
+		engine.WithApiUrl(server.URL),
+		engine.WithMiddleware(record, engine.HeaderMiddleware(http.Header{"X-Trace-Id": {"trace-1"}}), shortCircuit),
+	)
+
+	if _, err := client.GetBalance(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if traceID != "trace-1" {
+		t.Fatalf("expected header to be injected, got %q", traceID)
+	}
+
+	modelList, err := client.GetModels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(modelList.Data) != 1 || modelList.Data[0].ID != "cached" {
+		t.Fatalf("unexpected models: %+v", modelList)
+	}
+	if len(ops) != 2 || ops[0] != engine.OperationBalance || ops[1] != engine.OperationModels {
+		t.Fatalf("unexpected operations: %v", ops)
+	}
+}
@@ -0,0 +1,104 @@
+package demo_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/chat/agent"
+	"github.com/miajio/dpsk/dpsktest"
+	"github.com/miajio/dpsk/engine"
+	"github.com/miajio/dpsk/errors"
+)
+
+func TestMockServer(t *testing.T) {
+	server := dpsktest.NewServer(t)
+	server.Enqueue(dpsktest.Reasoning("先想想", "你好,小明"))
+
+	var retries []engine.RetryEvent
+	policy := engine.DefaultRetryPolicy()
+	policy.OnRetry = func(event engine.RetryEvent) { retries = append(retries, event) }
+	client := server.Client(engine.WithRetry(policy))
+
+	req, _ := chat.NewChatRequest(chat.WithModel("deepseek-chat"), chat.WithMessages(chat.Message{Role: "user", Content: "你好,我叫小明"}))
+	resp, err := client.Chat(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content() != "你好,小明" || resp.Reasoning() != "先想想" || resp.Usage.TotalTokens == 0 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	server.AssertLastMessage(t, "user", "你好,我叫小明")
+	server.AssertHeader(t, "Authorization", "Bearer test-key")
+
+	// 流式响应与 include_usage; 脚本耗尽后回显最后一条消息
+	streamReq, _ := chat.NewChatRequest(chat.WithModel("deepseek-chat"), chat.WithMessages(chat.Message{Role: "user", Content: "流式回显"}),
+		chat.WithStream(true), chat.WithStreamOptions(true))
+	resp, err = client.ChatStreamCollect(context.Background(), streamReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content() != "流式回显" || resp.Usage.TotalTokens == 0 {
+		t.Fatalf("unexpected stream response: %+v", resp)
+	}
+
+	// 429 携带 Retry-After, 500 后重试成功
+	server.Enqueue(dpsktest.RateLimited(time.Second), dpsktest.ServerError(), dpsktest.Text("重试成功"))
+	if resp, err = client.Chat(context.Background(), req); err != nil || resp.Content() != "重试成功" {
+		t.Fatalf("unexpected retry result: %v %v", resp, err)
+	}
+	if len(retries) != 2 || retries[0].StatusCode != http.StatusTooManyRequests || retries[0].Delay < time.Second {
+		t.Fatalf("unexpected retries: %+v", retries)
+	}
+
+	// 异常SSE
+	server.Enqueue(dpsktest.Malformed("半截"))
+	if _, err := client.ChatStreamCollect(context.Background(), streamReq); err == nil {
+		t.Fatal("expected malformed stream error")
+	}
+
+	// 慢速 keep-alive 超过 ctx 超时
+	server.Enqueue(dpsktest.Text("太慢了").Slow(5, 20*time.Millisecond))
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if _, err := client.ChatStreamCollect(ctx, streamReq); err == nil {
+		t.Fatal("expected timeout during keep-alive")
+	}
+
+	// 错误的 API Key
+	if _, err := server.Client(engine.WithApiKey("wrong")).GetBalance(context.Background()); err == nil {
+		t.Fatal("expected authentication error")
+	} else if apiErr := errors.ReadAPIError(err); apiErr == nil || apiErr.Status != "401 Unauthorized" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestMockServerToolCalls(t *testing.T) {
+	server := dpsktest.NewServer(t)
+	server.Enqueue(
+		dpsktest.ToolCalls(dpsktest.ToolCall("call_1", "get_weather", `{"city":"北京"}`)),
+		dpsktest.Text("北京今天晴"),
+	)
+
+	a := agent.New()
+	agent.RegisterTyped(a, "get_weather", "查询天气", func(ctx context.Context, args struct {
+		City string `json:"city" jsonschema:"required"`
+	}) (string, error) {
+		return args.City + ": 晴", nil
+	})
+	req, _ := chat.NewChatRequest(chat.WithModel("deepseek-chat"), chat.WithMessages(chat.Message{Role: "user", Content: "北京天气"}))
+	result, err := a.Run(context.Background(), server.Client(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Content() != "北京今天晴" {
+		t.Fatalf("unexpected result: %q", result.Content())
+	}
+	server.AssertRequestCount(t, dpsktest.PathChat, 2)
+	server.AssertLastMessage(t, "tool", "北京: 晴")
+	if server.Pending() != 0 {
+		t.Fatal("all replies should be consumed")
+	}
+}
@@ -0,0 +1,107 @@
+package demo_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/engine"
+	"github.com/miajio/dpsk/model"
+)
+
+func TestChatPrefix(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chat.ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		if r.URL.Path == "/chat/completions" {
+			// 放回历史的消息不能携带思维链
+			for _, msg := range req.Messages {
+				if msg.ReasoningContent != "" || msg.Prefix {
+					t.Errorf("unexpected message in history: %+v", msg)
+				}
+			}
+			fmt.Fprint(w, `{"id":"2","choices":[{"index":0,"message":{"role":"assistant","content":"好的"}}]}`)
+			return
+		}
+		if r.URL.Path != "/beta/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		last := req.Messages[len(req.Messages)-1]
+		if !last.Prefix {
+			t.Errorf("last message should be a prefix: %+v", last)
+		}
+		reasoning := ""
+		if last.ReasoningContent != "" {
+			reasoning = "所以用快速排序"
+		}
+		fmt.Fprintf(w, `{"id":"1","choices":[{"index":0,"message":{"role":"assistant","content":"\ndef quick_sort(arr):\n","reasoning_content":%q}}]}`, reasoning)
+	}))
+	defer server.Close()
+
+	client, _ := engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL), engine.WithBetaUrl(server.URL+"/beta"))
+	req, err := chat.NewChatRequest(
+		chat.WithModel(model.DeepSeekChat),
+		chat.WithMessages(chat.Message{Role: "user", Content: "写一个快速排序"}),
+		chat.WithAssistantPrefix("```python"),
+		chat.WithStop("```"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.ChatPrefix(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Continuation() != "\ndef quick_sort(arr):\n" || resp.FullContent() != "```python\ndef quick_sort(arr):\n" {
+		t.Fatalf("unexpected continuation: %q", resp.FullContent())
+	}
+
+	// 普通 Chat 也会将前缀续写请求发送到 beta 接口
+	if _, err := client.Chat(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	// 推理模型: 思维链前缀
+	req, _ = chat.NewChatRequest(
+		chat.WithModel(model.DeepSeekReasoner),
+		chat.WithMessages(chat.Message{Role: "user", Content: "写一个排序"}),
+		chat.WithReasoningPrefix("数据量很大, ", "```python"),
+	)
+	resp, err = client.ChatPrefix(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.FullReasoning() != "数据量很大, 所以用快速排序" || resp.Message().Prefix || resp.Message().ReasoningContent != "" {
+		t.Fatalf("unexpected reasoning: %q", resp.FullReasoning())
+	}
+
+	// 续写结果放回历史后继续与推理模型对话
+	req, err = chat.NewChatRequest(
+		chat.WithModel(model.DeepSeekReasoner),
+		chat.WithMessages(req.Messages[0], resp.Message(), chat.Message{Role: "user", Content: "改成归并排序"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Chat(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	// 最后一条消息不是前缀
+	req, _ = chat.NewChatRequest(chat.WithModel(model.DeepSeekChat), chat.WithMessages(chat.Message{Role: "user", Content: "你好"}))
+	if _, err := client.ChatPrefix(context.Background(), req); err == nil {
+		t.Fatal("expected prefix validation error")
+	}
+	// 非推理模型不支持思维链前缀
+	req.Messages = append(req.Messages, chat.NewPrefixMessage("好", "想"))
+	if _, err := client.ChatPrefix(context.Background(), req); err == nil {
+		t.Fatal("expected reasoning prefix error")
+	}
+}
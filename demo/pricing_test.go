@@ -0,0 +1,99 @@
+package demo_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/engine"
+	"github.com/miajio/dpsk/model"
+	"github.com/miajio/dpsk/pricing"
+)
+
+func TestPricingCost(t *testing.T) {
+	usage := chat.Usage{PromptTokens: 1500, PromptCacheHitTokens: 1000, PromptCacheMissTokens: 500, CompletionTokens: 200, TotalTokens: 1700}
+
+	// 北京时间 12:00, 标准价格
+	peak := time.Date(2025, 6, 3, 4, 0, 0, 0, time.UTC)
+	charge, err := pricing.Cost(usage, "deepseek-chat", peak)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1000*0.5/1e6 + 500*2/1e6 + 200*8/1e6 = 0.0005 + 0.001 + 0.0016
+	if charge.OffPeak || charge.Amounts[pricing.CNY].Total.String() != "0.0031" {
+		t.Fatalf("unexpected peak charge: %+v", charge)
+	}
+
+	// 北京时间 02:00, 五折
+	offPeak := time.Date(2025, 6, 3, 18, 0, 0, 0, time.UTC)
+	charge, _ = pricing.Cost(usage, "deepseek-chat", offPeak)
+	if !charge.OffPeak || charge.Amounts[pricing.CNY].Total.String() != "0.00155" {
+		t.Fatalf("unexpected off-peak charge: %+v", charge)
+	}
+
+	if _, err := pricing.Cost(usage, "unknown-model", peak); err == nil {
+		t.Fatal("expected error for unknown model")
+	}
+}
+
+func TestCostTracker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"1","choices":[{"index":0,"message":{"role":"assistant","content":"你好"}}],"usage":{"prompt_tokens":1000000,"prompt_cache_miss_tokens":1000000,"completion_tokens":0,"total_tokens":1000000}}`))
+	}))
+	defer server.Close()
+
+	table := pricing.NewTable(pricing.ModelPricing{
+		Model:  "deepseek-chat",
+		Prices: map[string]pricing.Price{pricing.CNY: {InputCacheMiss: model.MustDecimal("2")}},
+	})
+	tracker := pricing.NewTracker(table)
+	client, _ := engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL), engine.WithCostTracker(tracker))
+	chatReq, _ := chat.NewChatRequest(chat.WithModel("deepseek-chat"), chat.WithMessages(chat.Message{Role: "user", Content: "你好"}))
+
+	for i := 0; i < 3; i++ {
+		ctx := engine.ContextWithTag(context.Background(), "session-a")
+		if _, err := client.Chat(ctx, chatReq); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client.Chat(context.Background(), chatReq)
+
+	if spend := tracker.Tag("session-a"); spend.Requests != 3 || spend.Amounts[pricing.CNY].String() != "6.00" {
+		t.Fatalf("unexpected tag spend: %+v", spend)
+	}
+	if total := tracker.Total(); total.Requests != 4 || total.Amounts[pricing.CNY].String() != "8.00" {
+		t.Fatalf("unexpected total spend: %+v", total)
+	}
+
+	remaining := tracker.Remaining(&model.Balance{BalanceInfos: []model.BalanceInfo{{Currency: "CNY", TotalBalance: "110.00"}}})
+	if remaining[pricing.CNY].String() != "102.00" {
+		t.Fatalf("unexpected remaining: %v", remaining)
+	}
+}
+
+func TestDefaultTableIsolation(t *testing.T) {
+	usage := chat.Usage{PromptTokens: 1000000, PromptCacheMissTokens: 1000000, TotalTokens: 1000000}
+	at := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	before, err := pricing.Cost(usage, "deepseek-chat", at)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 修改一个累计器的默认价格表不影响其他累计器与包级计算
+	a := pricing.NewTracker(nil)
+	a.Table().Set(pricing.ModelPricing{Model: "deepseek-chat", Prices: map[string]pricing.Price{pricing.CNY: {InputCacheMiss: model.MustDecimal("100")}}})
+	b := pricing.NewTracker(nil)
+	p, _ := b.Table().Get("deepseek-chat")
+	p.Prices[pricing.CNY] = pricing.Price{}
+	p.Discounts[0].Percent = 100
+
+	charge, _ := b.Table().Cost(usage, "deepseek-chat", at)
+	after, _ := pricing.Cost(usage, "deepseek-chat", at)
+	if charge.Amounts[pricing.CNY].Total.String() != before.Amounts[pricing.CNY].Total.String() ||
+		after.Amounts[pricing.CNY].Total.String() != before.Amounts[pricing.CNY].Total.String() {
+		t.Fatalf("default table is shared: %v %v %v", before, charge, after)
+	}
+}
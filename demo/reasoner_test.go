@@ -0,0 +1,107 @@
+package demo_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/chat/conversation"
+	"github.com/miajio/dpsk/engine"
+	"github.com/miajio/dpsk/model"
+)
+
+func TestReasonerValidate(t *testing.T) {
+	req, err := chat.NewChatRequest(chat.WithModel(model.DeepSeekReasoner), chat.WithMessages(chat.Message{Role: "user", Content: "9.11 和 9.8 哪个大"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Temperature = 0.5
+	if err := req.Validate(); err != nil {
+		t.Fatalf("ignored parameter should not fail validation: %v", err)
+	}
+	if warnings := req.Warnings(); len(warnings) != 1 {
+		t.Fatalf("expected temperature warning, got %v", warnings)
+	}
+
+	req.Logprobs = true
+	if err := req.Validate(); err == nil {
+		t.Fatal("expected error for logprobs")
+	}
+	req.Logprobs = false
+
+	req.Messages = append(req.Messages,
+		chat.Message{Role: "assistant", Content: "9.8 更大", ReasoningContent: "比较小数部分"},
+		chat.Message{Role: "user", Content: "为什么"},
+	)
+	if err := req.Validate(); err == nil {
+		t.Fatal("expected error for reasoning_content in history")
+	}
+	req.Messages = chat.StripReasoning(req.Messages)
+	if err := req.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReasonerConversation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chat.ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		for _, msg := range req.Messages {
+			if msg.ReasoningContent != "" {
+				t.Errorf("reasoning_content should not be sent back: %+v", msg)
+			}
+		}
+		if !req.Stream {
+			fmt.Fprintf(w, `{"id":"1","choices":[{"index":0,"message":{"role":"assistant","content":"第%d轮","reasoning_content":"思考"}}]}`, len(req.Messages))
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"reasoning_content\":\"再想\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"回答\"}}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	client, _ := engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL))
+	conv, err := conversation.New(context.Background(), client, "reasoner",
+		conversation.WithRequestOptions(chat.WithModel(model.DeepSeekReasoner)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := conv.Send(context.Background(), "你好")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content() != "第1轮" || resp.Reasoning() != "思考" {
+		t.Fatalf("unexpected response: %q %q", resp.Content(), resp.Reasoning())
+	}
+
+	var reasoning, content string
+	resp, err = conv.SendStream(context.Background(), "继续", func(chunk chat.ChatResponse) error {
+		reasoning += chunk.Reasoning()
+		content += chunk.Content()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reasoning != "再想" || content != "回答" || resp.Reasoning() != "再想" {
+		t.Fatalf("unexpected stream: %q %q %q", reasoning, content, resp.Reasoning())
+	}
+
+	// 历史中保留思维链, 仅在请求中去除
+	if history := conv.History(); history[1].ReasoningContent != "思考" {
+		t.Fatalf("history should keep reasoning: %+v", history)
+	}
+	if _, err := conv.Send(context.Background(), "再见"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,82 @@
+package demo_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/engine"
+	"github.com/miajio/dpsk/model"
+)
+
+func TestModelRegistry(t *testing.T) {
+	var listCalls atomic.Int32
+	var lastBody atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/models" {
+			listCalls.Add(1)
+			w.Write([]byte(`{"object":"list","data":[{"id":"deepseek-chat","object":"model","owned_by":"deepseek"},{"id":"deepseek-reasoner","object":"model","owned_by":"deepseek"},{"id":"deepseek-new","object":"model","owned_by":"deepseek"}]}`))
+			return
+		}
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		lastBody.Store(body)
+		w.Write([]byte(`{"id":"1","choices":[{"index":0,"message":{"role":"assistant","content":"你好"}}]}`))
+	}))
+	defer server.Close()
+
+	client, _ := engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL),
+		engine.WithModelRegistry(time.Hour, engine.ParamPolicyReject))
+	models, err := client.Registry().List(context.Background())
+	if err != nil || len(models) != 3 {
+		t.Fatalf("unexpected models: %v %v", models, err)
+	}
+	if !models[1].Reasoning || models[1].Tools || models[2].Known {
+		t.Fatalf("unexpected capabilities: %+v", models)
+	}
+
+	reasonerReq, _ := chat.NewChatRequest(chat.WithModel(model.DeepSeekReasoner), chat.WithMessages(chat.Message{Role: "user", Content: "你好"}))
+	reasonerReq.Temperature = 0.7
+	reasonerReq.Logprobs = true
+	if _, err := client.Chat(context.Background(), reasonerReq); err == nil {
+		t.Fatal("expected unsupported parameter error")
+	}
+
+	missingReq, _ := chat.NewChatRequest(chat.WithModel("deepseek-missing"), chat.WithMessages(chat.Message{Role: "user", Content: "你好"}))
+	if _, err := client.Chat(context.Background(), missingReq); err == nil {
+		t.Fatal("expected model not available error")
+	}
+
+	// 未知的新模型不做能力校验
+	newReq, _ := chat.NewChatRequest(chat.WithModel("deepseek-new"), chat.WithMessages(chat.Message{Role: "user", Content: "你好"}))
+	newReq.Temperature = 0.7
+	if _, err := client.Chat(context.Background(), newReq); err != nil {
+		t.Fatal(err)
+	}
+
+	// 剔除模式: 发送前去掉不支持的参数, 不修改调用方的请求
+	client, _ = engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL),
+		engine.WithModelRegistry(time.Hour, engine.ParamPolicyStrip))
+	if _, err := client.Chat(context.Background(), reasonerReq); err != nil {
+		t.Fatal(err)
+	}
+	body := lastBody.Load().(map[string]any)
+	if _, ok := body["temperature"]; ok {
+		t.Fatalf("temperature should be stripped: %v", body)
+	}
+	if _, ok := body["logprobs"]; ok {
+		t.Fatalf("logprobs should be stripped: %v", body)
+	}
+	if reasonerReq.Temperature != 0.7 || !reasonerReq.Logprobs {
+		t.Fatal("caller's request should not be modified")
+	}
+	client.Chat(context.Background(), reasonerReq)
+	if n := listCalls.Load(); n != 2 {
+		t.Fatalf("expected model list to be cached per client, got %d calls", n)
+	}
+}
@@ -0,0 +1,90 @@
+package demo_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/engine"
+)
+
+func TestRetry(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"object":"list","data":[{"id":"deepseek-chat","object":"model","owned_by":"deepseek"}]}`))
+	}))
+	defer server.Close()
+
+	var retries []engine.RetryEvent
+	policy := engine.DefaultRetryPolicy()
+	policy.BaseDelay = time.Millisecond
+	policy.OnRetry = func(event engine.RetryEvent) {
+		retries = append(retries, event)
+	}
+
+	client, _ := engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL), engine.WithRetry(policy))
+	modelList, err := client.GetModels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(modelList.Data) != 1 || calls.Load() != 3 || len(retries) != 2 {
+		t.Fatalf("unexpected result: models=%d calls=%d retries=%d", len(modelList.Data), calls.Load(), len(retries))
+	}
+	if retries[0].StatusCode != http.StatusTooManyRequests || retries[1].Attempt != 3 {
+		t.Fatalf("unexpected retry events: %+v", retries)
+	}
+
+	calls.Store(-10)
+	policy.OnRetry = nil
+	client, _ = engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL), engine.WithRetry(policy))
+	if _, err := client.GetModels(context.Background()); err == nil {
+		t.Fatal("expected error after exhausting retries")
+	}
+}
+
+func TestRetryTransportError(t *testing.T) {
+	// 读取完请求后断开连接: 请求已送达, 对话请求默认不重试, 避免重复计费
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		io.Copy(io.Discard, r.Body)
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer server.Close()
+
+	policy := engine.DefaultRetryPolicy()
+	policy.BaseDelay = time.Millisecond
+	req, _ := chat.NewChatRequest(chat.WithModel("deepseek-chat"), chat.WithMessages(chat.Message{Role: "user", Content: "你好"}))
+	client, _ := engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL), engine.WithRetry(policy))
+	if _, err := client.Chat(context.Background(), req); err == nil || calls.Load() != 1 {
+		t.Fatalf("expected single attempt, calls=%d err=%v", calls.Load(), err)
+	}
+
+	policy.RetryUnsafeErrors = true
+	client, _ = engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL), engine.WithRetry(policy))
+	calls.Store(0)
+	if _, err := client.Chat(context.Background(), req); err == nil || calls.Load() != 3 {
+		t.Fatalf("expected 3 attempts, calls=%d err=%v", calls.Load(), err)
+	}
+
+	// 建立连接失败时请求未送达, 对话请求同样重试
+	server.Close()
+	var retries int
+	policy.RetryUnsafeErrors = false
+	policy.OnRetry = func(engine.RetryEvent) { retries++ }
+	client, _ = engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL), engine.WithRetry(policy))
+	if _, err := client.Chat(context.Background(), req); err == nil || retries != 2 {
+		t.Fatalf("expected dial errors to be retried, retries=%d err=%v", retries, err)
+	}
+}
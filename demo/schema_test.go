@@ -0,0 +1,74 @@
+package demo_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/miajio/dpsk/chat/agent"
+	"github.com/miajio/dpsk/chat/schema"
+)
+
+type Location struct {
+	City     string `json:"city" description:"城市名称" jsonschema:"required"`
+	District string `json:"district,omitempty"`
+}
+
+type WeatherArgs struct {
+	Location
+	Unit    string   `json:"unit" jsonschema:"enum=celsius|fahrenheit"`
+	Days    *int     `json:"days,omitempty" jsonschema:"minimum=1,maximum=7"`
+	Tags    []string `json:"tags,omitempty"`
+	Nearby  []Location
+	private string
+}
+
+func TestSchemaGenerate(t *testing.T) {
+	s, err := schema.For[WeatherArgs]()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(s)
+	expected := `{"type":"object","properties":{"Nearby":{"type":"array","items":{"type":"object","properties":{"city":{"type":"string","description":"城市名称"},"district":{"type":"string"}},"required":["city"]}},"city":{"type":"string","description":"城市名称"},"days":{"type":"integer","minimum":1,"maximum":7},"district":{"type":"string"},"tags":{"type":"array","items":{"type":"string"}},"unit":{"type":"string","enum":["celsius","fahrenheit"]}},"required":["city"]}`
+	if string(data) != expected {
+		t.Fatalf("unexpected schema:\n%s", data)
+	}
+}
+
+func TestSchemaDecode(t *testing.T) {
+	args, err := schema.Decode[WeatherArgs](`{"city":"北京","unit":"celsius","days":3}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args.City != "北京" || *args.Days != 3 {
+		t.Fatalf("unexpected args: %+v", args)
+	}
+
+	// 可为空的字段允许 null
+	if args, err = schema.Decode[WeatherArgs](`{"city":"北京","days":null,"tags":null}`); err != nil || args.Days != nil {
+		t.Fatalf("unexpected args: %+v, err=%v", args, err)
+	}
+
+	for _, arguments := range []string{
+		`{"unit":"celsius"}`,
+		`{"city":"北京","unit":"kelvin"}`,
+		`{"city":"北京","days":10}`,
+		`{"city":"北京","days":1.5}`,
+		`{"city":"北京","Nearby":[{"district":"朝阳"}]}`,
+		`{"city":null}`,
+		`{"city":"北京","unit":null}`,
+		`null`,
+		`{"city":`,
+	} {
+		if _, err := schema.Decode[WeatherArgs](arguments); err == nil {
+			t.Errorf("expected validation error for %s", arguments)
+		}
+	}
+
+	handler := agent.Typed(func(ctx context.Context, args WeatherArgs) (string, error) {
+		return args.City + ": 晴", nil
+	})
+	if output, err := handler(context.Background(), `{"city":"上海"}`); err != nil || output != "上海: 晴" {
+		t.Fatalf("unexpected output: %s, err=%v", output, err)
+	}
+}
@@ -0,0 +1,299 @@
+package demo_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miajio/dpsk/cache"
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/engine"
+	"github.com/miajio/dpsk/errors"
+)
+
+// streamChunks 模拟的流式响应块, 包含思维链、内容、工具调用片段与用量
+var streamChunks = []string{
+	`{"id":"1","object":"chat.completion.chunk","created":1,"model":"deepseek-chat","choices":[{"index":0,"delta":{"role":"assistant","content":"","reasoning_content":"想一想"}}]}`,
+	`{"id":"1","object":"chat.completion.chunk","created":1,"model":"deepseek-chat","choices":[{"index":0,"delta":{"content":"你好"}}]}`,
+	`{"id":"1","object":"chat.completion.chunk","created":1,"model":"deepseek-chat","choices":[{"index":0,"delta":{"content":",小明"}}]}`,
+	`{"id":"1","object":"chat.completion.chunk","created":1,"model":"deepseek-chat","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":"}}]}}]}`,
+	`{"id":"1","object":"chat.completion.chunk","created":1,"model":"deepseek-chat","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"北京\"}"}}]},"finish_reason":"tool_calls"}]}`,
+	`{"id":"1","object":"chat.completion.chunk","created":1,"model":"deepseek-chat","choices":[],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`,
+}
+
+// newStreamServer 创建返回 streamChunks 的SSE服务
+func newStreamServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range streamChunks {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+}
+
+func TestChatStreamCollect(t *testing.T) {
+	server := newStreamServer()
+	defer server.Close()
+
+	client, _ := engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL))
+	chatReq, _ := chat.NewChatRequest(
+		chat.WithModel("deepseek-chat"),
+		chat.WithMessages(chat.Message{Role: "user", Content: "你好,我叫小明"}),
+		chat.WithStream(true),
+		chat.WithStreamOptions(true),
+	)
+
+	res, err := client.ChatStreamCollect(context.Background(), chatReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Choices) != 1 {
+		t.Fatalf("expected 1 choice, got %d", len(res.Choices))
+	}
+	msg := res.Choices[0].Message
+	if msg.Role != "assistant" || msg.Content != "你好,小明" || msg.ReasoningContent != "想一想" {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+	if len(msg.ToolCalls) != 1 || msg.ToolCalls[0].ID != "call_1" || msg.ToolCalls[0].Function.Arguments != `{"city":"北京"}` {
+		t.Fatalf("unexpected tool calls: %+v", msg.ToolCalls)
+	}
+	if res.Choices[0].FinishReason != "tool_calls" || res.Usage.TotalTokens != 15 || res.Object != "chat.completion" {
+		t.Fatalf("unexpected response: %+v", res)
+	}
+}
+
+func TestChatStreamIterator(t *testing.T) {
+	server := newStreamServer()
+	defer server.Close()
+
+	client, _ := engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL))
+	chatReq, _ := chat.NewChatRequest(
+		chat.WithModel("deepseek-chat"),
+		chat.WithMessages(chat.Message{Role: "user", Content: "你好,我叫小明"}),
+		chat.WithStream(true),
+	)
+
+	stream, err := client.ChatStream(context.Background(), chatReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for _, err := range stream.All() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		count++
+		if count == 2 {
+			break
+		}
+	}
+	if count != 2 || stream.Next() {
+		t.Fatalf("expected stream to be closed after break, count=%d", count)
+	}
+}
+
+func TestChatStreamCancel(t *testing.T) {
+	block := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "data: %s\n\n", streamChunks[1])
+		w.(http.Flusher).Flush()
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(block)
+
+	client, _ := engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL))
+	chatReq, _ := chat.NewChatRequest(
+		chat.WithModel("deepseek-chat"),
+		chat.WithMessages(chat.Message{Role: "user", Content: "你好,我叫小明"}),
+		chat.WithStream(true),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.ChatStream(ctx, chatReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stream.Next() {
+		t.Fatalf("expected first chunk, err=%v", stream.Err())
+	}
+	cancel()
+	if stream.Next() {
+		t.Fatal("expected stream to stop after cancel")
+	}
+	if stream.Err() != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", stream.Err())
+	}
+}
+
+func TestSSEReader(t *testing.T) {
+	large := strings.Repeat("好", 100*1024)
+	input := ": keep-alive\r\n\r\n" +
+		"event: message\r\nid: 1\r\ndata: first\r\ndata: second\r\n\r\n" +
+		": keep-alive\n\n" +
+		"retry: 3000\ndata:" + large + "\n\n" +
+		"data: incomplete"
+
+	reader := engine.NewSSEReader(strings.NewReader(input))
+	event, err := reader.Next()
+	if err != nil || event.Event != "message" || event.ID != "1" || event.Data != "first\nsecond" {
+		t.Fatalf("unexpected event: %+v, err=%v", event, err)
+	}
+	event, err = reader.Next()
+	if err != nil || event.Data != large || event.ID != "1" || event.Retry != 3000 {
+		t.Fatalf("unexpected large event, err=%v", err)
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestChatStreamErrorEvent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		fmt.Fprintf(w, "data: %s\n\n", streamChunks[1])
+		fmt.Fprint(w, "data: {\"error\":{\"message\":\"server overloaded\",\"type\":\"server_error\"}}\n\n")
+	}))
+	defer server.Close()
+
+	client, _ := engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL))
+	chatReq, _ := chat.NewChatRequest(
+		chat.WithModel("deepseek-chat"),
+		chat.WithMessages(chat.Message{Role: "user", Content: "你好,我叫小明"}),
+		chat.WithStream(true),
+	)
+
+	_, err := client.ChatStreamCollect(context.Background(), chatReq)
+	apiErr := errors.ReadAPIError(err)
+	if apiErr == nil || apiErr.Type != "server_error" || apiErr.Message != "server overloaded" {
+		t.Fatalf("expected in-stream api error, got %v", err)
+	}
+}
+
+func TestChatStreamConcurrentClose(t *testing.T) {
+	block := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "data: %s\n\n", streamChunks[1])
+		w.(http.Flusher).Flush()
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(block)
+
+	// 开启缓存使关闭回调读取流的状态与累加结果
+	client, _ := engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL), engine.WithCache(cache.NewMemory(1, 0)))
+	chatReq, _ := chat.NewChatRequest(
+		chat.WithModel("deepseek-chat"),
+		chat.WithMessages(chat.Message{Role: "user", Content: "你好,我叫小明"}),
+		chat.WithStream(true),
+	)
+	stream, err := client.ChatStream(context.Background(), chatReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan int)
+	go func() {
+		count := 0
+		for stream.Next() {
+			count++
+		}
+		done <- count
+	}()
+	time.Sleep(50 * time.Millisecond)
+	stream.Close()
+	if count := <-done; count != 1 {
+		t.Fatalf("expected 1 chunk before close, got %d", count)
+	}
+}
+
+func TestSSEReaderCR(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	reader := engine.NewSSEReader(pr)
+	events := make(chan *engine.SSEEvent)
+	go func() {
+		for {
+			event, err := reader.Next()
+			if err != nil {
+				close(events)
+				return
+			}
+			events <- event
+		}
+	}()
+
+	// 仅以 CR 结尾的事件无需等待下一个字节即可读取
+	go pw.Write([]byte("data: first\r\r"))
+	select {
+	case event := <-events:
+		if event.Data != "first" {
+			t.Fatalf("unexpected event: %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("CR-terminated event was not delivered")
+	}
+
+	// 跨数据块的 CRLF 只算一个换行符
+	go func() {
+		pw.Write([]byte("\ndata: second\r"))
+		pw.Write([]byte("\n\r\n"))
+	}()
+	select {
+	case event := <-events:
+		if event.Data != "second" {
+			t.Fatalf("unexpected event: %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("CRLF event was not delivered")
+	}
+}
+
+func TestChatStreamEmptyDataAndCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data:\n\n")
+		fmt.Fprintf(w, "data: %s\n\n", streamChunks[1])
+		fmt.Fprint(w, "data: \n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	client, _ := engine.NewClient(engine.WithApiKey("test"), engine.WithApiUrl(server.URL))
+	chatReq, _ := chat.NewChatRequest(
+		chat.WithModel("deepseek-chat"),
+		chat.WithMessages(chat.Message{Role: "user", Content: "你好"}),
+		chat.WithStream(true),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.ChatStream(ctx, chatReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	// 空的 data 事件被跳过; 响应已全部缓冲, 读到第一个响应块后取消 ctx, 随后读到的 [DONE] 仍正常结束
+	chunks := 0
+	for stream.Next() {
+		chunks++
+		cancel()
+	}
+	if chunks != 1 || stream.Err() != nil {
+		t.Fatalf("unexpected stream: chunks=%d err=%v", chunks, stream.Err())
+	}
+}
@@ -0,0 +1,62 @@
+package demo_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/tokenizer"
+)
+
+// tokenizerJSON 最小的字节级BPE词表: 单字节token + "he" "ll" "hell" "hello" 的合并规则
+const tokenizerJSON = `{
+	"added_tokens": [{"id": 100, "content": "<｜User｜>"}],
+	"model": {
+		"type": "BPE",
+		"vocab": {"h": 0, "e": 1, "l": 2, "o": 3, "Ġ": 4, "w": 5, "r": 6, "d": 7, "he": 8, "ll": 9, "hell": 10, "hello": 11, "Ġw": 12},
+		"merges": ["h e", "l l", ["he", "ll"], "hell o", "Ġ w"]
+	}
+}`
+
+func TestTokenizer(t *testing.T) {
+	tk, err := tokenizer.Load(strings.NewReader(tokenizerJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := tk.Encode("<｜User｜>hello world")
+	expected := []int{100, 11, 12, 3, 6, 2, 7}
+	if len(ids) != len(expected) {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+	for i := range ids {
+		if ids[i] != expected[i] {
+			t.Fatalf("unexpected ids: %v", ids)
+		}
+	}
+
+	msgs := []chat.Message{{Role: "user", Content: "hello"}}
+	if n := tokenizer.CountMessages(tk, msgs); n != 1+tokenizer.PerMessageTokens {
+		t.Fatalf("unexpected message tokens: %d", n)
+	}
+
+	req := &chat.ChatRequest{Model: "deepseek-chat", Messages: msgs, MaxTokens: 10}
+	if _, err := tokenizer.CheckRequest(tk, req, 100); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tokenizer.CheckRequest(tk, req, 10); err == nil {
+		t.Fatal("expected context length error")
+	}
+
+	// 未指定上下文长度时使用模型能力表, 未知模型不做校验
+	req.MaxTokens = 128 * 1024
+	if _, err := tokenizer.CheckRequest(tk, req, 0); err == nil {
+		t.Fatal("expected context length error from model capabilities")
+	}
+	req.Model = "unknown-model"
+	if _, err := tokenizer.CheckRequest(tk, req, 0); err != nil {
+		t.Fatal(err)
+	}
+	if n := (tokenizer.Estimator{}).CountTokens("你好"); n != 1 {
+		t.Fatalf("unexpected estimate: %d", n)
+	}
+}
@@ -0,0 +1,39 @@
+package demo_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/miajio/dpsk/chat"
+)
+
+func TestToolCallMessage(t *testing.T) {
+	body := `{"id":"1","object":"chat.completion","model":"deepseek-chat","choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","content":"","tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"北京\"}"}}]}}]}`
+	var res chat.ChatResponse
+	if err := json.Unmarshal([]byte(body), &res); err != nil {
+		t.Fatal(err)
+	}
+	msg := res.Choices[0].Message
+	if len(msg.ToolCalls) != 1 || msg.ToolCalls[0].Function.Name != "get_weather" {
+		t.Fatalf("tool calls not decoded: %+v", msg)
+	}
+
+	chatReq, err := chat.NewChatRequest(chat.WithModel("deepseek-chat"), chat.WithMessages(chat.Message{Role: "user", Content: "北京天气如何?"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := chatReq.AddMessages(msg); err != nil {
+		t.Fatalf("assistant tool call message without content should be valid: %v", err)
+	}
+	if err := chatReq.AddMessages(chat.Message{Role: "tool", Content: "晴"}); err == nil {
+		t.Fatal("expected error for tool message without tool_call_id")
+	}
+	if err := chatReq.AddToolMessage("call_1", "晴, 25℃"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := json.Marshal(chatReq.Messages[2])
+	if string(data) != `{"role":"tool","content":"晴, 25℃","tool_call_id":"call_1"}` {
+		t.Fatalf("unexpected tool message json: %s", data)
+	}
+}
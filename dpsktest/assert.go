@@ -0,0 +1,95 @@
+package dpsktest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/miajio/dpsk/chat"
+)
+
+// Requests 返回收到的所有请求
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// ChatRequests 返回收到的所有对话请求(含 beta 前缀续写)
+func (s *Server) ChatRequests() []*chat.ChatRequest {
+	var reqs []*chat.ChatRequest
+	for _, r := range s.Requests() {
+		if r.Chat != nil {
+			reqs = append(reqs, r.Chat)
+		}
+	}
+	return reqs
+}
+
+// Reset 清空已记录的请求与未消费的回复
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+	s.replies = nil
+}
+
+// AssertRequestCount 断言指定路径收到的请求数, path 为空时统计所有请求
+func (s *Server) AssertRequestCount(t testing.TB, path string, want int) {
+	t.Helper()
+	got := 0
+	for _, r := range s.Requests() {
+		if path == "" || r.Path == path {
+			got++
+		}
+	}
+	if got != want {
+		t.Errorf("dpsktest: expected %d requests to %q, got %d", want, path, got)
+	}
+}
+
+// LastChat 返回最后一个对话请求, 没有对话请求时测试失败
+func (s *Server) LastChat(t testing.TB) *chat.ChatRequest {
+	t.Helper()
+	reqs := s.ChatRequests()
+	if len(reqs) == 0 {
+		t.Fatal("dpsktest: no chat request received")
+	}
+	return reqs[len(reqs)-1]
+}
+
+// AssertLastMessage 断言最后一个对话请求的最后一条消息的角色与内容
+func (s *Server) AssertLastMessage(t testing.TB, role, content string) {
+	t.Helper()
+	req := s.LastChat(t)
+	if len(req.Messages) == 0 {
+		t.Fatal("dpsktest: last chat request has no messages")
+	}
+	msg := req.Messages[len(req.Messages)-1]
+	if msg.Role != role || msg.Content != content {
+		t.Errorf("dpsktest: expected last message %s %q, got %s %q", role, content, msg.Role, msg.Content)
+	}
+}
+
+// AssertHeader 断言最后一个请求的请求头包含指定值
+func (s *Server) AssertHeader(t testing.TB, key, value string) {
+	t.Helper()
+	reqs := s.Requests()
+	if len(reqs) == 0 {
+		t.Fatal("dpsktest: no request received")
+	}
+	if got := reqs[len(reqs)-1].Header.Get(key); got != value {
+		t.Errorf("dpsktest: expected header %s %q, got %q", key, value, got)
+	}
+}
+
+// AssertBodyContains 断言最后一个请求的请求体包含指定内容
+func (s *Server) AssertBodyContains(t testing.TB, substr string) {
+	t.Helper()
+	reqs := s.Requests()
+	if len(reqs) == 0 {
+		t.Fatal("dpsktest: no request received")
+	}
+	if body := string(reqs[len(reqs)-1].Body); !strings.Contains(body, substr) {
+		t.Errorf("dpsktest: expected body to contain %q, got %s", substr, body)
+	}
+}
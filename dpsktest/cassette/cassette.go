@@ -0,0 +1,398 @@
+// Package cassette 录制与回放 HTTP 交互, 用于对 engine.Client 做确定性的测试
+//
+// 录制模式下请求经真实的 http.RoundTripper 发出, 请求与响应(含流式SSE响应体及其时序)保存到 cassette 文件;
+// 回放模式下按 方法+路径+规范化请求体 匹配已录制的交互并返回, 不访问网络:
+//
+//	rec := cassette.Use(t, "testdata/chat.json", cassette.WithRedactFields("user"))
+//	client, _ := engine.NewClient(engine.WithApiKey(key), engine.WithHttpClient(rec.HTTPClient()))
+//
+// Authorization 请求头始终脱敏, 请求体中通过 WithRedactFields 指定的 JSON 字段会被替换为 [REDACTED]
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miajio/dpsk/errors"
+)
+
+// Redacted 脱敏后的占位值
+const Redacted = "[REDACTED]"
+
+// Mode 工作模式
+type Mode int
+
+const (
+	ModeAuto   Mode = iota // cassette 文件存在时回放, 否则录制
+	ModeReplay             // 仅回放, 未匹配的请求返回错误
+	ModeRecord             // 录制, 覆盖已有的 cassette 文件
+)
+
+// Chunk 响应体片段, 用于保存流式响应的时序
+type Chunk struct {
+	Delay time.Duration `json:"delay"` // 距上一个片段(或响应头)的间隔
+	Data  string        `json:"data"`  // 片段内容
+}
+
+// RecordedRequest 录制的请求
+type RecordedRequest struct {
+	Method string      `json:"method"`         // 请求方法
+	Path   string      `json:"path"`           // 请求路径
+	Query  string      `json:"query"`          // 查询参数
+	Header http.Header `json:"header"`         // 请求头, Authorization 已脱敏
+	Body   string      `json:"body,omitempty"` // 规范化并脱敏后的请求体
+}
+
+// RecordedResponse 录制的响应, 流式响应保存在 Chunks 中
+type RecordedResponse struct {
+	Status int         `json:"status"`           // 状态码
+	Header http.Header `json:"header"`           // 响应头
+	Body   string      `json:"body,omitempty"`   // 非流式响应体
+	Chunks []Chunk     `json:"chunks,omitempty"` // 流式响应体片段
+}
+
+// Interaction 一次请求与响应
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// Option 录制器选项
+type Option func(*Recorder)
+
+// WithMode 设置工作模式, 默认 ModeAuto
+func WithMode(mode Mode) Option {
+	return func(r *Recorder) {
+		r.mode = mode
+	}
+}
+
+// WithTransport 设置录制时使用的真实 RoundTripper, 默认 http.DefaultTransport
+func WithTransport(transport http.RoundTripper) Option {
+	return func(r *Recorder) {
+		r.transport = transport
+	}
+}
+
+// WithRedactFields 设置需要脱敏的请求体JSON字段名, 任意层级的同名字段都会被替换
+func WithRedactFields(fields ...string) Option {
+	return func(r *Recorder) {
+		r.redactFields = append(r.redactFields, fields...)
+	}
+}
+
+// WithRedactHeaders 设置除 Authorization 外需要脱敏的请求头
+func WithRedactHeaders(headers ...string) Option {
+	return func(r *Recorder) {
+		r.redactHeaders = append(r.redactHeaders, headers...)
+	}
+}
+
+// WithReplayTiming 回放流式响应时按录制的间隔发送片段, 默认立即发送
+func WithReplayTiming(enabled bool) Option {
+	return func(r *Recorder) {
+		r.replayTiming = enabled
+	}
+}
+
+// Recorder 录制与回放 HTTP 交互的 http.RoundTripper, 可并发使用
+type Recorder struct {
+	path          string
+	mode          Mode
+	transport     http.RoundTripper
+	redactFields  []string
+	redactHeaders []string
+	replayTiming  bool
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	used         []bool
+}
+
+// New 创建录制器, 回放模式下加载 cassette 文件
+func New(path string, opts ...Option) (*Recorder, error) {
+	r := &Recorder{path: path, transport: http.DefaultTransport, redactHeaders: []string{"Authorization"}}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.mode == ModeAuto {
+		r.mode = ModeRecord
+		if _, err := os.Stat(path); err == nil {
+			r.mode = ModeReplay
+		}
+	}
+	if r.mode == ModeReplay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &r.interactions); err != nil {
+			return nil, errors.NewF("cassette: failed to parse %s: %v", path, err)
+		}
+		r.used = make([]bool, len(r.interactions))
+	}
+	return r, nil
+}
+
+// Mode 返回实际的工作模式
+func (r *Recorder) Mode() Mode {
+	return r.mode
+}
+
+// HTTPClient 返回使用该录制器的 http.Client, 可用于 engine.WithHttpClient
+func (r *Recorder) HTTPClient() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Interactions 返回已录制或已加载的交互
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := make([]Interaction, len(r.interactions))
+	for i, in := range r.interactions {
+		result[i] = *in
+	}
+	return result
+}
+
+// Save 录制模式下将交互写入 cassette 文件, 回放模式下不做任何操作
+// 应在所有响应体关闭后调用, 未读完的流式响应只保存已读取的部分
+func (r *Recorder) Save() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.interactions, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, data, 0o644)
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+	recorded := RecordedRequest{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  req.URL.RawQuery,
+		Header: r.redactHeader(req.Header),
+		Body:   r.normalize(body),
+	}
+
+	if r.mode == ModeReplay {
+		return r.replay(req, recorded)
+	}
+	return r.record(req, body, recorded)
+}
+
+// replay 返回匹配的已录制响应
+func (r *Recorder) replay(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	r.mu.Lock()
+	var found *Interaction
+	for i, in := range r.interactions {
+		if !r.used[i] && matches(in.Request, recorded) {
+			r.used[i] = true
+			found = in
+			break
+		}
+	}
+	r.mu.Unlock()
+	if found == nil {
+		return nil, errors.NewF("cassette: no recorded interaction for %s %s", recorded.Method, recorded.Path)
+	}
+
+	var body io.ReadCloser
+	if isEventStream(found.Response.Header) {
+		body = &replayBody{ctx: req.Context().Done(), chunks: found.Response.Chunks, timing: r.replayTiming}
+	} else {
+		body = io.NopCloser(strings.NewReader(found.Response.Body))
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", found.Response.Status, http.StatusText(found.Response.Status)),
+		StatusCode:    found.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        found.Response.Header.Clone(),
+		Body:          body,
+		ContentLength: -1,
+		Request:       req,
+	}, nil
+}
+
+// record 发送真实请求并录制响应, 流式响应在读取过程中记录片段
+func (r *Recorder) record(req *http.Request, body []byte, recorded RecordedRequest) (*http.Response, error) {
+	// RoundTripper 不能修改原请求, 请求体已读取, 使用副本发送
+	out := req.Clone(req.Context())
+	if body != nil {
+		out.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	resp, err := r.transport.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+
+	in := &Interaction{
+		Request:  recorded,
+		Response: RecordedResponse{Status: resp.StatusCode, Header: resp.Header.Clone()},
+	}
+	stream := isEventStream(resp.Header)
+	if stream {
+		in.Response.Chunks = []Chunk{}
+	}
+	r.mu.Lock()
+	r.interactions = append(r.interactions, in)
+	r.mu.Unlock()
+
+	if stream {
+		resp.Body = &recordBody{ReadCloser: resp.Body, recorder: r, in: in, last: time.Now()}
+		return resp, nil
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	in.Response.Body = string(data)
+	r.mu.Unlock()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return resp, nil
+}
+
+// isEventStream 是否为SSE流式响应
+func isEventStream(header http.Header) bool {
+	return strings.HasPrefix(header.Get("Content-Type"), "text/event-stream")
+}
+
+// redactHeader 复制请求头并脱敏
+func (r *Recorder) redactHeader(header http.Header) http.Header {
+	h := header.Clone()
+	for _, key := range r.redactHeaders {
+		if h.Get(key) != "" {
+			h.Set(key, Redacted)
+		}
+	}
+	return h
+}
+
+// normalize 规范化请求体: JSON 按键排序并脱敏, 其他内容原样保留
+func (r *Recorder) normalize(body []byte) string {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return ""
+	}
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return string(body)
+	}
+	value = redact(value, r.redactFields)
+	data, err := json.Marshal(value)
+	if err != nil {
+		return string(body)
+	}
+	return string(data)
+}
+
+// redact 递归替换指定字段的值
+func redact(value any, fields []string) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if slices.Contains(fields, key) {
+				v[key] = Redacted
+				continue
+			}
+			v[key] = redact(item, fields)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redact(item, fields)
+		}
+	}
+	return value
+}
+
+// matches 按方法、路径、查询参数与规范化请求体匹配
+func matches(recorded, req RecordedRequest) bool {
+	return recorded.Method == req.Method && recorded.Path == req.Path &&
+		recorded.Query == req.Query && recorded.Body == req.Body
+}
+
+// recordBody 录制流式响应体
+type recordBody struct {
+	io.ReadCloser
+	recorder *Recorder
+	in       *Interaction
+	last     time.Time
+}
+
+// Read 读取并记录片段及其间隔
+func (b *recordBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		now := time.Now()
+		b.recorder.mu.Lock()
+		b.in.Response.Chunks = append(b.in.Response.Chunks, Chunk{Delay: now.Sub(b.last), Data: string(p[:n])})
+		b.recorder.mu.Unlock()
+		b.last = now
+	}
+	return n, err
+}
+
+// replayBody 回放流式响应体
+type replayBody struct {
+	ctx    <-chan struct{}
+	chunks []Chunk
+	timing bool
+	buf    []byte
+}
+
+// Read 依次返回片段, 开启时序回放时等待录制的间隔
+func (b *replayBody) Read(p []byte) (int, error) {
+	for len(b.buf) == 0 {
+		if len(b.chunks) == 0 {
+			return 0, io.EOF
+		}
+		chunk := b.chunks[0]
+		b.chunks = b.chunks[1:]
+		if b.timing && chunk.Delay > 0 {
+			timer := time.NewTimer(chunk.Delay)
+			select {
+			case <-timer.C:
+			case <-b.ctx:
+				timer.Stop()
+				return 0, errors.New("cassette: request canceled")
+			}
+		}
+		b.buf = []byte(chunk.Data)
+	}
+	n := copy(p, b.buf)
+	b.buf = b.buf[n:]
+	return n, nil
+}
+
+// Close 关闭响应体
+func (b *replayBody) Close() error {
+	return nil
+}
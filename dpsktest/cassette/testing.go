@@ -0,0 +1,18 @@
+package cassette
+
+import "testing"
+
+// Use 创建录制器并在测试结束时保存 cassette 文件, 出错时测试失败
+func Use(t testing.TB, path string, opts ...Option) *Recorder {
+	t.Helper()
+	r, err := New(path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := r.Save(); err != nil {
+			t.Error(err)
+		}
+	})
+	return r
+}
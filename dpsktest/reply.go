@@ -0,0 +1,92 @@
+package dpsktest
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/miajio/dpsk/chat"
+)
+
+// Reply 对话接口的脚本化回复, 同时用于非流式与流式请求
+// Status 不为0且不为200时返回错误响应, 其余字段描述正常回复的内容与时序
+type Reply struct {
+	Content          string          // 回复内容
+	ReasoningContent string          // 思维链内容
+	ToolCalls        []chat.ToolCall // 工具调用
+	FinishReason     string          // 完成原因, 为空时有工具调用为 tool_calls, 否则为 stop
+	Usage            *chat.Usage     // 用量, 为空时按请求与回复内容估算
+
+	Status     int           // 错误状态码, 如 429、500
+	Message    string        // 错误信息
+	RetryAfter time.Duration // 错误响应的 Retry-After 头, 为0时不设置
+
+	KeepAlives        int           // 回复前发送的 keep-alive 次数, 流式为 SSE 注释, 非流式为空行
+	KeepAliveInterval time.Duration // keep-alive 间隔
+	ChunkDelay        time.Duration // 流式响应块之间的间隔
+	ChunkSize         int           // 流式内容每块的字符数, 默认2
+	MalformedSSE      bool          // 流式响应在第一个响应块之后插入无法解析的数据
+}
+
+// Text 返回内容为 content 的回复
+func Text(content string) Reply {
+	return Reply{Content: content}
+}
+
+// Reasoning 返回带思维链的回复
+func Reasoning(reasoningContent, content string) Reply {
+	return Reply{Content: content, ReasoningContent: reasoningContent}
+}
+
+// ToolCalls 返回调用工具的回复
+func ToolCalls(calls ...chat.ToolCall) Reply {
+	return Reply{ToolCalls: calls}
+}
+
+// ToolCall 创建工具调用, arguments 为JSON字符串
+func ToolCall(id, name, arguments string) chat.ToolCall {
+	return chat.ToolCall{ID: id, Type: "function", Function: chat.FunctionCall{Name: name, Arguments: arguments}}
+}
+
+// Error 返回错误响应
+func Error(status int, message string) Reply {
+	return Reply{Status: status, Message: message}
+}
+
+// RateLimited 返回带 Retry-After 的 429 响应
+func RateLimited(retryAfter time.Duration) Reply {
+	return Reply{Status: http.StatusTooManyRequests, Message: "Rate Limit Reached", RetryAfter: retryAfter}
+}
+
+// ServerError 返回 500 响应
+func ServerError() Reply {
+	return Reply{Status: http.StatusInternalServerError, Message: "Server Error"}
+}
+
+// Malformed 返回流式响应中包含无法解析数据的回复
+func Malformed(content string) Reply {
+	return Reply{Content: content, MalformedSSE: true}
+}
+
+// Slow 在回复前发送 n 次间隔为 interval 的 keep-alive
+func (r Reply) Slow(n int, interval time.Duration) Reply {
+	r.KeepAlives = n
+	r.KeepAliveInterval = interval
+	return r
+}
+
+// failed 是否为错误响应
+func (r Reply) failed() bool {
+	return r.Status != 0 && r.Status != http.StatusOK
+}
+
+// finishReason 返回完成原因
+func (r Reply) finishReason() string {
+	switch {
+	case r.FinishReason != "":
+		return r.FinishReason
+	case len(r.ToolCalls) > 0:
+		return "tool_calls"
+	default:
+		return "stop"
+	}
+}
@@ -0,0 +1,417 @@
+// Package dpsktest 提供基于 httptest 的 DeepSeek 模拟服务, 用于离线测试基于 engine.Client 的代码
+//
+// 模拟服务实现 /models、/user/balance 与 /chat/completions(含 beta 前缀续写), 支持流式与非流式请求,
+// 按脚本依次返回回复, 未设置脚本时回显最后一条消息; 可注入 429、500、异常SSE与慢速 keep-alive 等故障,
+// 并记录收到的请求供断言:
+//
+//	server := dpsktest.NewServer(t)
+//	server.Enqueue(dpsktest.RateLimited(time.Second), dpsktest.Text("你好"))
+//	client := server.Client(engine.WithRetry(engine.DefaultRetryPolicy()))
+//	resp, err := client.Chat(ctx, req)
+//	server.AssertRequestCount(t, dpsktest.PathChat, 2)
+package dpsktest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/engine"
+	"github.com/miajio/dpsk/model"
+	"github.com/miajio/dpsk/tokenizer"
+)
+
+// 模拟服务的接口路径
+const (
+	PathModels   = "/models"
+	PathBalance  = "/user/balance"
+	PathChat     = "/chat/completions"
+	PathBetaChat = "/beta/chat/completions"
+)
+
+const (
+	defaultAPIKey    = "test-key" // 默认 API Key
+	defaultChunkSize = 2          // 流式内容每块的默认字符数
+)
+
+// Request 模拟服务收到的请求
+type Request struct {
+	Method string            // 请求方法
+	Path   string            // 请求路径
+	Header http.Header       // 请求头
+	Body   []byte            // 请求体
+	Chat   *chat.ChatRequest // 对话请求体, 其他接口为空
+}
+
+// Option 模拟服务选项
+type Option func(*Server)
+
+// WithModels 设置模型列表, 默认为 deepseek-chat 与 deepseek-reasoner
+func WithModels(ids ...string) Option {
+	return func(s *Server) {
+		s.models = make([]model.Model, 0, len(ids))
+		for _, id := range ids {
+			s.models = append(s.models, model.Model{ID: id, Object: "model", OwnedBy: "deepseek"})
+		}
+	}
+}
+
+// WithBalance 设置余额, 默认人民币 100.00
+func WithBalance(balance model.Balance) Option {
+	return func(s *Server) {
+		s.balance = balance
+	}
+}
+
+// WithDefaultReply 设置脚本耗尽后的回复, 默认回显最后一条消息的内容
+func WithDefaultReply(reply Reply) Option {
+	return func(s *Server) {
+		s.defaultReply = &reply
+	}
+}
+
+// WithAPIKey 设置校验的 API Key, 默认 test-key
+func WithAPIKey(apiKey string) Option {
+	return func(s *Server) {
+		s.apiKey = apiKey
+	}
+}
+
+// Server DeepSeek 模拟服务, 可并发使用
+type Server struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	apiKey       string
+	models       []model.Model
+	balance      model.Balance
+	replies      []Reply
+	defaultReply *Reply
+	requests     []Request
+}
+
+// NewServer 创建并启动模拟服务, 测试结束时自动关闭
+func NewServer(t testing.TB, opts ...Option) *Server {
+	s := &Server{
+		apiKey: defaultAPIKey,
+		balance: model.Balance{
+			IsAvailable:  true,
+			BalanceInfos: []model.BalanceInfo{{Currency: model.CurrencyCNY, TotalBalance: "100.00", GrantedBalance: "0.00", ToppedUpBalance: "100.00"}},
+		},
+	}
+	WithModels(model.DeepSeekChat, model.DeepSeekReasoner)(s)
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Client 创建连接到模拟服务的客户端, opts 追加在默认配置之后
+func (s *Server) Client(opts ...engine.Option) *engine.Client {
+	options := []engine.Option{
+		engine.WithApiKey(s.apiKey),
+		engine.WithApiUrl(s.URL),
+		engine.WithBetaUrl(s.URL + "/beta"),
+	}
+	client, _ := engine.NewClient(append(options, opts...)...)
+	return client
+}
+
+// Enqueue 追加脚本化回复, 对话请求按顺序依次消费
+func (s *Server) Enqueue(replies ...Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies = append(s.replies, replies...)
+}
+
+// Pending 返回尚未消费的回复数
+func (s *Server) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.replies)
+}
+
+// SetBalance 设置余额
+func (s *Server) SetBalance(balance model.Balance) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.balance = balance
+}
+
+// handle 路由请求
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	recorded := Request{Method: r.Method, Path: r.URL.Path, Header: r.Header.Clone(), Body: body}
+
+	if r.Header.Get("Authorization") != "Bearer "+s.apiKey {
+		s.record(recorded)
+		writeError(w, Error(http.StatusUnauthorized, "Authentication Fails, Your api key is invalid"))
+		return
+	}
+
+	switch r.URL.Path {
+	case PathModels:
+		s.record(recorded)
+		s.mu.Lock()
+		list := model.ModelList{Object: "list", Data: append([]model.Model(nil), s.models...)}
+		s.mu.Unlock()
+		writeJSON(w, list)
+	case PathBalance:
+		s.record(recorded)
+		s.mu.Lock()
+		balance := s.balance
+		s.mu.Unlock()
+		writeJSON(w, balance)
+	case PathChat, PathBetaChat:
+		var req chat.ChatRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			s.record(recorded)
+			writeError(w, Error(http.StatusBadRequest, "Invalid request body: "+err.Error()))
+			return
+		}
+		recorded.Chat = &req
+		s.record(recorded)
+		s.chat(w, &req)
+	default:
+		s.record(recorded)
+		writeError(w, Error(http.StatusNotFound, "Not Found"))
+	}
+}
+
+// record 记录请求
+func (s *Server) record(r Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r)
+}
+
+// next 取出下一个回复
+func (s *Server) next(req *chat.ChatRequest) Reply {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.replies) > 0 {
+		reply := s.replies[0]
+		s.replies = s.replies[1:]
+		return reply
+	}
+	if s.defaultReply != nil {
+		return *s.defaultReply
+	}
+	if len(req.Messages) == 0 {
+		return Text("")
+	}
+	return Text(req.Messages[len(req.Messages)-1].Content)
+}
+
+// chat 处理对话请求
+func (s *Server) chat(w http.ResponseWriter, req *chat.ChatRequest) {
+	reply := s.next(req)
+	if reply.failed() {
+		writeError(w, reply)
+		return
+	}
+	usage := reply.usage(req)
+	if req.Stream {
+		s.stream(w, req, reply, usage)
+		return
+	}
+
+	keepAlive(w, reply, "\n")
+	writeJSON(w, chat.ChatResponse{
+		ID:      "chatcmpl-dpsktest",
+		Object:  "chat.completion",
+		Created: int(time.Now().Unix()),
+		Model:   req.Model,
+		Choices: []chat.Choice{{
+			Index:        0,
+			FinishReason: reply.finishReason(),
+			Message: chat.Message{
+				Role:             "assistant",
+				Content:          reply.Content,
+				ReasoningContent: reply.ReasoningContent,
+				ToolCalls:        reply.ToolCalls,
+			},
+		}},
+		Usage: usage,
+	})
+}
+
+// stream 以SSE返回回复, 思维链与内容按 ChunkSize 切分, 工具调用参数分两段发送
+func (s *Server) stream(w http.ResponseWriter, req *chat.ChatRequest, reply Reply, usage chat.Usage) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	keepAlive(w, reply, ": keep-alive\n\n")
+
+	created := int(time.Now().Unix())
+	first := true
+	send := func(delta chat.Message, finishReason string) {
+		if first {
+			delta.Role = "assistant"
+		}
+		chunk := map[string]any{
+			"id":      "chatcmpl-dpsktest",
+			"object":  "chat.completion.chunk",
+			"created": created,
+			"model":   req.Model,
+			"choices": []map[string]any{{"index": 0, "delta": delta, "finish_reason": nullable(finishReason)}},
+		}
+		writeEvent(w, chunk)
+		if first && reply.MalformedSSE {
+			fmt.Fprint(w, "data: {\"id\":\"chatcmpl-dpsktest\",\"choices\":[{\n\n")
+			flush(w)
+		}
+		first = false
+		if reply.ChunkDelay > 0 {
+			time.Sleep(reply.ChunkDelay)
+		}
+	}
+
+	size := reply.ChunkSize
+	if size <= 0 {
+		size = defaultChunkSize
+	}
+	for _, part := range split(reply.ReasoningContent, size) {
+		send(chat.Message{ReasoningContent: part}, "")
+	}
+	for _, part := range split(reply.Content, size) {
+		send(chat.Message{Content: part}, "")
+	}
+	for i, call := range reply.ToolCalls {
+		args := call.Function.Arguments
+		half := len(args) / 2
+		head := call
+		head.Index = i
+		head.Function.Arguments = args[:half]
+		send(chat.Message{ToolCalls: []chat.ToolCall{head}}, "")
+		tail := chat.ToolCall{Index: i, Function: chat.FunctionCall{Arguments: args[half:]}}
+		send(chat.Message{ToolCalls: []chat.ToolCall{tail}}, "")
+	}
+	send(chat.Message{}, reply.finishReason())
+
+	if req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+		writeEvent(w, map[string]any{
+			"id":      "chatcmpl-dpsktest",
+			"object":  "chat.completion.chunk",
+			"created": created,
+			"model":   req.Model,
+			"choices": []any{},
+			"usage":   usage,
+		})
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	flush(w)
+}
+
+// usage 返回回复的用量, 未设置时按请求与回复内容估算
+func (r Reply) usage(req *chat.ChatRequest) chat.Usage {
+	if r.Usage != nil {
+		return *r.Usage
+	}
+	counter := tokenizer.Estimator{}
+	prompt := tokenizer.CountRequest(counter, req)
+	reasoning := counter.CountTokens(r.ReasoningContent)
+	completion := reasoning + counter.CountTokens(r.Content)
+	for _, call := range r.ToolCalls {
+		completion += counter.CountTokens(call.Function.Name) + counter.CountTokens(call.Function.Arguments)
+	}
+	return chat.Usage{
+		PromptTokens:            prompt,
+		PromptCacheMissTokens:   prompt,
+		CompletionTokens:        completion,
+		TotalTokens:             prompt + completion,
+		CompletionTokensDetails: chat.CompletionTokensDetails{ReasoningTokens: reasoning},
+	}
+}
+
+// keepAlive 发送 keep-alive
+func keepAlive(w http.ResponseWriter, reply Reply, line string) {
+	for i := 0; i < reply.KeepAlives; i++ {
+		fmt.Fprint(w, line)
+		flush(w)
+		time.Sleep(reply.KeepAliveInterval)
+	}
+}
+
+// writeJSON 写入JSON响应
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeEvent 写入一个SSE数据事件
+func writeEvent(w http.ResponseWriter, v any) {
+	data, _ := json.Marshal(v)
+	fmt.Fprintf(w, "data: %s\n\n", data)
+	flush(w)
+}
+
+// writeError 写入 DeepSeek 格式的错误响应
+func writeError(w http.ResponseWriter, reply Reply) {
+	if reply.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int((reply.RetryAfter+time.Second-1)/time.Second)))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(reply.Status)
+	body := map[string]any{"error": map[string]any{
+		"message": reply.Message,
+		"type":    errorType(reply.Status),
+		"param":   nil,
+		"code":    errorType(reply.Status),
+	}}
+	json.NewEncoder(w).Encode(body)
+}
+
+// errorType 状态码对应的错误类型
+func errorType(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	default:
+		if status >= 500 {
+			return "server_error"
+		}
+		return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+	}
+}
+
+// nullable 空字符串序列化为 null
+func nullable(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// split 按字符数切分字符串
+func split(s string, size int) []string {
+	var parts []string
+	runes := []rune(s)
+	for len(runes) > 0 {
+		n := min(size, len(runes))
+		parts = append(parts, string(runes[:n]))
+		runes = runes[n:]
+	}
+	return parts
+}
+
+// flush 立即发送已写入的数据
+func flush(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,184 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/miajio/dpsk/model"
+)
+
+// defaultWatchInterval 余额轮询的默认间隔
+const defaultWatchInterval = 5 * time.Minute
+
+// BalanceEvent 余额越过阈值事件
+type BalanceEvent struct {
+	Currency  string         // 币种
+	Threshold model.Decimal  // 越过的阈值
+	Balance   model.Decimal  // 当前总余额
+	Below     bool           // true 表示降至阈值以下, false 表示回升至阈值及以上
+	Raw       *model.Balance // 本次查询的原始余额
+	At        time.Time      // 查询时间
+}
+
+// WatchOption 余额监控选项
+type WatchOption func(*BalanceWatcher)
+
+// WithWatchInterval 设置轮询间隔, 默认5分钟
+func WithWatchInterval(interval time.Duration) WatchOption {
+	return func(w *BalanceWatcher) {
+		w.interval = interval
+	}
+}
+
+// WithWatchCurrency 设置监控的币种, 默认 CNY
+func WithWatchCurrency(currency string) WatchOption {
+	return func(w *BalanceWatcher) {
+		w.currency = currency
+	}
+}
+
+// WithThresholds 设置余额阈值, 余额降至阈值以下或回升时产生事件
+func WithThresholds(thresholds ...model.Decimal) WatchOption {
+	return func(w *BalanceWatcher) {
+		w.thresholds = append(w.thresholds, thresholds...)
+	}
+}
+
+// WithBalanceCallback 设置事件回调, 在轮询 goroutine 中同步调用
+func WithBalanceCallback(callback func(BalanceEvent)) WatchOption {
+	return func(w *BalanceWatcher) {
+		w.onEvent = callback
+	}
+}
+
+// WithWatchErrorHandler 设置查询余额失败时的回调
+func WithWatchErrorHandler(handler func(error)) WatchOption {
+	return func(w *BalanceWatcher) {
+		w.onError = handler
+	}
+}
+
+// BalanceWatcher 余额监控, 按间隔轮询 GetBalance 并在余额越过阈值时产生事件
+// 事件同时发送到回调与 Events 通道, 通道已满时丢弃事件
+type BalanceWatcher struct {
+	client     *Client
+	interval   time.Duration
+	currency   string
+	thresholds []model.Decimal
+	onEvent    func(BalanceEvent)
+	onError    func(error)
+
+	events chan BalanceEvent
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu     sync.Mutex
+	latest *model.Balance
+	below  []bool // 各阈值当前是否处于余额以下
+}
+
+// WatchBalance 启动余额监控, 立即查询一次, 之后按间隔轮询, 直到 ctx 取消或调用 Stop
+func (c *Client) WatchBalance(ctx context.Context, opts ...WatchOption) *BalanceWatcher {
+	w := &BalanceWatcher{
+		client:   c,
+		interval: defaultWatchInterval,
+		currency: model.CurrencyCNY,
+		events:   make(chan BalanceEvent, 16),
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.interval <= 0 {
+		w.interval = defaultWatchInterval
+	}
+	w.below = make([]bool, len(w.thresholds))
+
+	ctx, w.cancel = context.WithCancel(ctx)
+	go w.run(ctx)
+	return w
+}
+
+// run 轮询余额
+func (w *BalanceWatcher) run(ctx context.Context) {
+	defer close(w.done)
+	defer close(w.events)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		w.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll 查询一次余额并检查阈值
+func (w *BalanceWatcher) poll(ctx context.Context) {
+	balance, err := w.client.GetBalance(ctx)
+	if err == nil {
+		var total model.Decimal
+		if total, err = balance.Total(w.currency); err == nil {
+			w.check(balance, total, time.Now())
+			return
+		}
+	}
+	if w.onError != nil && ctx.Err() == nil {
+		w.onError(err)
+	}
+}
+
+// check 比较阈值状态, 状态变化时产生事件; 首次查询时余额已低于阈值也会产生事件
+func (w *BalanceWatcher) check(balance *model.Balance, total model.Decimal, at time.Time) {
+	var events []BalanceEvent
+	w.mu.Lock()
+	w.latest = balance
+	for i, threshold := range w.thresholds {
+		below := total.Cmp(threshold) < 0
+		if below == w.below[i] {
+			continue
+		}
+		w.below[i] = below
+		events = append(events, BalanceEvent{
+			Currency:  w.currency,
+			Threshold: threshold,
+			Balance:   total,
+			Below:     below,
+			Raw:       balance,
+			At:        at,
+		})
+	}
+	w.mu.Unlock()
+
+	for _, event := range events {
+		if w.onEvent != nil {
+			w.onEvent(event)
+		}
+		select {
+		case w.events <- event:
+		default:
+		}
+	}
+}
+
+// Events 返回事件通道, 监控停止后关闭
+func (w *BalanceWatcher) Events() <-chan BalanceEvent {
+	return w.events
+}
+
+// Latest 返回最近一次查询到的余额, 尚未查询成功时为 nil
+func (w *BalanceWatcher) Latest() *model.Balance {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.latest
+}
+
+// Stop 停止监控并等待轮询 goroutine 退出
+func (w *BalanceWatcher) Stop() {
+	w.cancel()
+	<-w.done
+}
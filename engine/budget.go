@@ -0,0 +1,275 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/completion"
+	"github.com/miajio/dpsk/errors"
+	"github.com/miajio/dpsk/model"
+	"github.com/miajio/dpsk/pricing"
+	"github.com/miajio/dpsk/tokenizer"
+)
+
+// 预算范围
+const (
+	BudgetScopeTotal   = "total"   // 客户端总预算
+	BudgetScopeDaily   = "daily"   // 每日预算
+	BudgetScopeTag     = "tag"     // 标签预算
+	BudgetScopeBalance = "balance" // 账户余额
+)
+
+// defaultEstimatedOutputTokens 请求未设置 MaxTokens 时估算的输出token数
+const defaultEstimatedOutputTokens = 4096
+
+// Budget 预算配置, 金额为零值的项不限制
+type Budget struct {
+	Currency        string                   // 币种, 默认 CNY
+	Total           model.Decimal            // 客户端总预算
+	Daily           model.Decimal            // 每日预算, 按 Location 的自然日计算
+	PerTag          model.Decimal            // 每个标签的默认预算
+	TagLimits       map[string]model.Decimal // 指定标签的预算, 优先于 PerTag
+	MinBalance      model.Decimal            // 估算的剩余余额低于该值时拒绝请求
+	RefreshInterval time.Duration            // 余额刷新间隔, 为0时不检查余额
+	Location        *time.Location           // 计算自然日的时区, 默认 time.Local
+	Table           *pricing.Table           // 价格表, 默认官方价格表
+}
+
+// ErrBudgetExceeded 请求会超出预算, 在发送请求前返回
+type ErrBudgetExceeded struct {
+	Scope     string        // 超出的预算范围
+	Tag       string        // 标签预算超出时的标签
+	Currency  string        // 币种
+	Limit     model.Decimal // 预算上限(余额范围为最低余额)
+	Spent     model.Decimal // 已花费(含进行中的请求), 余额范围为估算的剩余余额
+	Estimated model.Decimal // 本次请求的估算费用
+}
+
+// Error 错误信息
+func (e *ErrBudgetExceeded) Error() string {
+	if e.Scope == BudgetScopeBalance {
+		return fmt.Sprintf("budget exceeded: balance %s %s minus estimated %s is below %s", e.Spent, e.Currency, e.Estimated, e.Limit)
+	}
+	scope := e.Scope
+	if e.Tag != "" {
+		scope += " " + e.Tag
+	}
+	return fmt.Sprintf("budget exceeded: %s spent %s + estimated %s > limit %s %s", scope, e.Spent, e.Estimated, e.Limit, e.Currency)
+}
+
+// Unwrap 返回对应的 CodeError, 便于通过 errors.ReadCodeError 读取错误码
+func (e *ErrBudgetExceeded) Unwrap() error {
+	return errors.NewCodeError(http.StatusPaymentRequired, e.Error())
+}
+
+// budgetGuard 预算守卫
+type budgetGuard struct {
+	budget Budget
+	client *Client
+
+	mu         sync.Mutex
+	total      model.Decimal            // 已花费(含进行中)
+	day        string                   // 当前自然日
+	daily      model.Decimal            // 当日已花费(含进行中)
+	tags       map[string]model.Decimal // 各标签已花费(含进行中)
+	pending    model.Decimal            // 进行中请求的预占费用
+	balance    *model.Decimal           // 最近一次获取的余额
+	since      model.Decimal            // 获取余额后已结算的花费
+	fetched    time.Time                // 最近一次获取余额的时间
+	refreshing chan struct{}            // 正在获取余额时非空, 获取结束后关闭
+	refreshErr error                    // 最近一次获取余额的错误
+}
+
+// newBudgetGuard 创建预算守卫
+func newBudgetGuard(c *Client, budget Budget) *budgetGuard {
+	if budget.Currency == "" {
+		budget.Currency = pricing.CNY
+	}
+	if budget.Location == nil {
+		budget.Location = time.Local
+	}
+	if budget.Table == nil {
+		budget.Table = pricing.DefaultTable()
+	}
+	return &budgetGuard{budget: budget, client: c, tags: make(map[string]model.Decimal)}
+}
+
+// estimate 估算请求费用: 输入全部按缓存未命中计价, 输出按 MaxTokens 或模型默认输出长度计价
+func (g *budgetGuard) estimate(modelName string, input, maxTokens int, at time.Time) model.Decimal {
+	output := maxTokens
+	if output <= 0 {
+		output = defaultEstimatedOutputTokens
+		if caps, ok := model.LookupCapabilities(modelName); ok && caps.DefaultOutput > 0 {
+			output = caps.DefaultOutput
+		}
+	}
+	usage := chat.Usage{PromptTokens: input, PromptCacheMissTokens: input, CompletionTokens: output, TotalTokens: input + output}
+	return g.cost(modelName, usage, at)
+}
+
+// cost 计算费用, 价格表中没有的模型或币种视为0
+func (g *budgetGuard) cost(modelName string, usage chat.Usage, at time.Time) model.Decimal {
+	charge, err := g.budget.Table.Cost(usage, modelName, at)
+	if err != nil {
+		return model.Decimal{}
+	}
+	return charge.Amounts[g.budget.Currency].Total
+}
+
+// refreshBalance 余额过期时重新获取, 获取失败时沿用上一次的余额
+// 同一时间只有一个请求获取余额, 其余请求沿用上一次的余额, 尚无余额时等待获取结束
+func (g *budgetGuard) refreshBalance(ctx context.Context) error {
+	if g.budget.RefreshInterval <= 0 {
+		return nil
+	}
+	g.mu.Lock()
+	if g.balance != nil && (g.refreshing != nil || time.Since(g.fetched) < g.budget.RefreshInterval) {
+		g.mu.Unlock()
+		return nil
+	}
+	if wait := g.refreshing; wait != nil {
+		g.mu.Unlock()
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if g.balance == nil {
+			return g.refreshErr
+		}
+		return nil
+	}
+	done := make(chan struct{})
+	g.refreshing = done
+	// 获取余额期间结算的花费可能未计入返回的余额, 获取结束后保留
+	base := g.since
+	g.mu.Unlock()
+
+	amount, err := g.fetchBalance(ctx)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.refreshing = nil
+	close(done)
+	g.refreshErr = err
+	if err != nil {
+		if g.balance != nil {
+			return nil
+		}
+		return err
+	}
+	g.balance = &amount
+	g.since = g.since.Sub(base)
+	g.fetched = time.Now()
+	return nil
+}
+
+// fetchBalance 获取预算币种的余额
+func (g *budgetGuard) fetchBalance(ctx context.Context) (model.Decimal, error) {
+	balance, err := g.client.GetBalance(ctx)
+	if err != nil {
+		return model.Decimal{}, err
+	}
+	return balance.Total(g.budget.Currency)
+}
+
+// reserve 检查预算并预占估算费用, 返回的 settle 在请求结束后以实际用量结算
+// usage 为 nil 时(如流式响应未返回用量)按估算费用结算
+// 请求失败时以零用量结算
+// input 为估算的输入token数
+func (g *budgetGuard) reserve(ctx context.Context, modelName string, input, maxTokens int) (settle func(usage *chat.Usage), err error) {
+	if err := g.refreshBalance(ctx); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	tag := TagFromContext(ctx)
+	estimated := g.estimate(modelName, input, maxTokens, now)
+	exceeded := func(scope string, limit, spent model.Decimal) error {
+		e := &ErrBudgetExceeded{Scope: scope, Currency: g.budget.Currency, Limit: limit, Spent: spent, Estimated: estimated}
+		if scope == BudgetScopeTag {
+			e.Tag = tag
+		}
+		return e
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if day := now.In(g.budget.Location).Format(time.DateOnly); day != g.day {
+		g.day = day
+		g.daily = model.Decimal{}
+	}
+	day := g.day
+	if limit := g.budget.Total; limit.Sign() > 0 && g.total.Add(estimated).Cmp(limit) > 0 {
+		return nil, exceeded(BudgetScopeTotal, limit, g.total)
+	}
+	if limit := g.budget.Daily; limit.Sign() > 0 && g.daily.Add(estimated).Cmp(limit) > 0 {
+		return nil, exceeded(BudgetScopeDaily, limit, g.daily)
+	}
+	if tag != "" {
+		limit, ok := g.budget.TagLimits[tag]
+		if !ok {
+			limit = g.budget.PerTag
+		}
+		if limit.Sign() > 0 && g.tags[tag].Add(estimated).Cmp(limit) > 0 {
+			return nil, exceeded(BudgetScopeTag, limit, g.tags[tag])
+		}
+	}
+	if g.balance != nil {
+		remaining := g.balance.Sub(g.since).Sub(g.pending)
+		if remaining.Sub(estimated).Cmp(g.budget.MinBalance) < 0 {
+			return nil, exceeded(BudgetScopeBalance, g.budget.MinBalance, remaining)
+		}
+	}
+
+	g.add(tag, day, estimated)
+	g.pending = g.pending.Add(estimated)
+	var once sync.Once
+	return func(usage *chat.Usage) {
+		once.Do(func() {
+			actual := estimated
+			if usage != nil {
+				actual = g.cost(modelName, *usage, now)
+			}
+			g.mu.Lock()
+			defer g.mu.Unlock()
+			g.add(tag, day, actual.Sub(estimated))
+			g.pending = g.pending.Sub(estimated)
+			g.since = g.since.Add(actual)
+		})
+	}, nil
+}
+
+// add 累加花费, 调用方需持有锁
+func (g *budgetGuard) add(tag, day string, amount model.Decimal) {
+	g.total = g.total.Add(amount)
+	if day == g.day {
+		g.daily = g.daily.Add(amount)
+	}
+	if tag != "" {
+		g.tags[tag] = g.tags[tag].Add(amount)
+	}
+}
+
+// reserveBudget 检查对话请求的预算, 未设置预算守卫时直接放行
+func (c *Client) reserveBudget(ctx context.Context, req *chat.ChatRequest) (func(usage *chat.Usage), error) {
+	if c.budget == nil {
+		return func(*chat.Usage) {}, nil
+	}
+	return c.budget.reserve(ctx, req.Model, tokenizer.CountRequest(tokenizer.Estimator{}, req), req.MaxTokens)
+}
+
+// reserveCompletionBudget 检查FIM补全请求的预算, 未设置预算守卫时直接放行
+func (c *Client) reserveCompletionBudget(ctx context.Context, req *completion.CompletionRequest) (func(usage *chat.Usage), error) {
+	if c.budget == nil {
+		return func(*chat.Usage) {}, nil
+	}
+	return c.budget.reserve(ctx, req.Model, completionInputTokens(req), req.MaxTokens)
+}
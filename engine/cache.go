@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/miajio/dpsk/cache"
+	"github.com/miajio/dpsk/chat"
+)
+
+// CacheMode 单次请求的缓存策略
+type CacheMode int
+
+const (
+	CacheDefault CacheMode = iota // 命中时返回缓存, 未命中时请求接口并写入缓存
+	CacheBypass                   // 不读取也不写入缓存
+	CacheRefresh                  // 不读取缓存, 请求接口后覆盖缓存
+)
+
+// cacheModeKey 缓存策略的 context key
+type cacheModeKey struct{}
+
+// ContextWithCacheMode 为请求设置缓存策略, 未设置时为 CacheDefault
+func ContextWithCacheMode(ctx context.Context, mode CacheMode) context.Context {
+	return context.WithValue(ctx, cacheModeKey{}, mode)
+}
+
+// CacheModeFromContext 读取缓存策略
+func CacheModeFromContext(ctx context.Context) CacheMode {
+	mode, _ := ctx.Value(cacheModeKey{}).(CacheMode)
+	return mode
+}
+
+// responseCache 对话响应缓存
+// 缓存读写失败时视为未命中, 不影响请求本身
+type responseCache struct {
+	store cache.Store
+}
+
+// lookup 查询缓存, 返回缓存键与命中的响应; 未开启缓存或策略为 CacheBypass 时键为空
+func (rc *responseCache) lookup(ctx context.Context, req *chat.ChatRequest) (string, *chat.ChatResponse) {
+	if rc == nil {
+		return "", nil
+	}
+	mode := CacheModeFromContext(ctx)
+	if mode == CacheBypass {
+		return "", nil
+	}
+	key, err := cache.Key(req)
+	if err != nil || mode == CacheRefresh {
+		return key, nil
+	}
+	data, ok, err := rc.store.Get(key)
+	if err != nil || !ok {
+		return key, nil
+	}
+	var resp chat.ChatResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return key, nil
+	}
+	return key, &resp
+}
+
+// save 写入缓存
+func (rc *responseCache) save(key string, resp *chat.ChatResponse) {
+	if rc == nil || key == "" {
+		return
+	}
+	if data, err := json.Marshal(resp); err == nil {
+		rc.store.Set(key, data)
+	}
+}
+
+// cachedStream 将缓存的完整响应回放为流式响应
+// 每个选项的内容、思维链与工具调用合并为一个响应块, 请求开启 include_usage 时最后追加用量块
+func cachedStream(ctx context.Context, req *chat.ChatRequest, resp *chat.ChatResponse) *Stream[chat.ChatResponse] {
+	chunk := chat.ChatResponse{
+		ID:                resp.ID,
+		Created:           resp.Created,
+		Model:             resp.Model,
+		SystemFingerprint: resp.SystemFingerprint,
+		Object:            "chat.completion.chunk",
+	}
+	content := chunk
+	for _, choice := range resp.Choices {
+		delta := choice.Message
+		if len(delta.ToolCalls) > 0 {
+			delta.ToolCalls = make([]chat.ToolCall, len(choice.Message.ToolCalls))
+			for i, call := range choice.Message.ToolCalls {
+				call.Index = i
+				delta.ToolCalls[i] = call
+			}
+		}
+		content.Choices = append(content.Choices, chat.Choice{
+			Index:        choice.Index,
+			Delta:        delta,
+			FinishReason: choice.FinishReason,
+			Logprobs:     choice.Logprobs,
+		})
+	}
+	chunks := []chat.ChatResponse{content}
+	if req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+		usage := chunk
+		usage.Choices = []chat.Choice{}
+		usage.Usage = resp.Usage
+		chunks = append(chunks, usage)
+	}
+
+	var buf bytes.Buffer
+	for _, c := range chunks {
+		data, _ := json.Marshal(c)
+		buf.WriteString("data: ")
+		buf.Write(data)
+		buf.WriteString("\n\n")
+	}
+	buf.WriteString("data: [DONE]\n\n")
+	httpResp := &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+		Body:       io.NopCloser(&buf),
+	}
+	return newStream[chat.ChatResponse](ctx, httpResp, nil)
+}
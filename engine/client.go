@@ -5,29 +5,55 @@ import (
 	"context"
 	"encoding/json"
 	"io"
+	"log/slog"
+	"maps"
 	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
 	"time"
 
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/completion"
 	"github.com/miajio/dpsk/errors"
+	"github.com/miajio/dpsk/pricing"
 )
 
 const (
-	apiUrl = "https://api.deepseek.com"
+	apiUrl  = "https://api.deepseek.com"
+	betaUrl = "https://api.deepseek.com/beta"
 )
 
 var (
 	defaultUrlMap = map[string]string{
-		"models":  "/models",
-		"balance": "/user/balance",
-		"chat":    "/chat/completions",
+		"models":      "/models",
+		"balance":     "/user/balance",
+		"chat":        "/chat/completions",
+		"completions": "/completions",
 	}
 )
 
 type Client struct {
 	httpClient *http.Client      // http客户端
 	apiUrl     string            // api接口默认调用地址
+	betaUrl    string            // beta接口调用地址(FIM补全、对话前缀续写)
 	urlMap     map[string]string // urlMap
 	apiKey     string            // apiKey
+
+	retryPolicy *RetryPolicy // 重试策略, 为空时不重试
+	limiter     *limiter     // 限流器, 为空时不限流
+	middlewares []Middleware // 中间件
+	handler     Handler      // 组装后的中间件链
+
+	costTracker *pricing.Tracker // 花费累计器
+	cache       *responseCache   // 响应缓存, 为空时不缓存
+	budget      *budgetGuard     // 预算守卫, 为空时不限制
+
+	logger           *slog.Logger // 日志, 为空时不输出
+	logRedactContent bool         // 日志中的消息内容是否脱敏
+
+	registry    *Registry     // 模型注册表
+	registryTTL time.Duration // 模型列表缓存时间
+	paramPolicy ParamPolicy   // 不支持参数的处理方式
 }
 
 // NewClient 创建一个client
@@ -35,11 +61,17 @@ func NewClient(options ...Option) (*Client, error) {
 	c := &Client{
 		httpClient: &http.Client{},
 		apiUrl:     apiUrl,
-		urlMap:     defaultUrlMap,
+		betaUrl:    betaUrl,
+		urlMap:     maps.Clone(defaultUrlMap),
 	}
 	for _, option := range options {
 		option(c)
 	}
+	if c.logger != nil {
+		c.logger = slog.New(&redactHandler{Handler: c.logger.Handler(), secret: func() string { return c.apiKey }})
+	}
+	c.handler = chainMiddlewares(c.send, c.middlewares)
+	c.registry = newRegistry(c, c.registryTTL)
 	return c, nil
 }
 
@@ -79,29 +111,119 @@ func (c *Client) WithTimeout(timeout time.Duration) *Client {
 	return c
 }
 
-// makeRequest 创建请求
-func (c *Client) makeRequest(ctx context.Context, method string, url string, body any) (*http.Response, error) {
+// makeRequest 创建请求, 经过中间件链后发送
+func (c *Client) makeRequest(ctx context.Context, op Operation, method string, url string, body any) (*http.Response, error) {
 	if c.apiKey == "" {
 		return nil, errors.NewCodeError(http.StatusBadRequest, "api key is required")
 	}
+	return c.handler(ctx, &Request{Operation: op, Method: method, Url: url, Body: body})
+}
 
-	var reqBody io.Reader
-	if body != nil {
-		jsonBody, err := json.Marshal(body)
+// send 中间件链末端的处理函数: 序列化请求体、限流并按重试策略发送
+func (c *Client) send(ctx context.Context, req *Request) (*http.Response, error) {
+	var jsonBody []byte
+	if req.Body != nil {
+		var err error
+		if jsonBody, err = json.Marshal(req.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.limiter != nil {
+		tokens := 0
+		switch body := req.Body.(type) {
+		case *chat.ChatRequest:
+			tokens = EstimateTokens(body)
+		case *completion.CompletionRequest:
+			tokens = estimateCompletionTokens(body)
+		}
+		release, err := c.limiter.acquire(ctx, tokens)
 		if err != nil {
 			return nil, err
 		}
-		reqBody = bytes.NewBuffer(jsonBody)
+		resp, err := c.retryRequest(ctx, req, jsonBody, tokens)
+		return withRelease(resp, err, release)
 	}
+	return c.retryRequest(ctx, req, jsonBody, 0)
+}
 
-	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+// retryRequest 按重试策略发送请求, 重试同样占用限流的请求数与token数配额
+func (c *Client) retryRequest(ctx context.Context, r *Request, jsonBody []byte, tokens int) (*http.Response, error) {
+	maxAttempts := 1
+	if c.retryPolicy != nil && c.retryPolicy.MaxAttempts > 1 {
+		maxAttempts = c.retryPolicy.MaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		c.logRequestStarted(ctx, r, attempt)
+		start := time.Now()
+		resp, sent, err := c.doRequest(ctx, r, jsonBody)
+		c.logRequestFinished(ctx, r, attempt, resp, err, time.Since(start))
+		if attempt >= maxAttempts || ctx.Err() != nil {
+			return resp, err
+		}
+		if err == nil && !c.retryPolicy.retryable(resp.StatusCode) {
+			return resp, nil
+		}
+		if err != nil && !c.retryPolicy.retryableError(r.Method, sent, err) {
+			return nil, err
+		}
+
+		delay := c.retryPolicy.backoff(attempt, resp)
+		event := RetryEvent{Attempt: attempt + 1, Delay: delay, Err: err, Method: r.Method, Url: r.Url}
+		if resp != nil {
+			event.StatusCode = resp.StatusCode
+			discardBody(resp)
+		}
+		c.logRetry(ctx, r, event)
+		if c.retryPolicy.OnRetry != nil {
+			c.retryPolicy.OnRetry(event)
+		}
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+		if c.limiter != nil {
+			if err := c.limiter.wait(ctx, tokens); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// doRequest 发送单次请求, sent 表示请求可能已送达服务端
+// 已建立连接但请求未写完时 sent 为 false; 无法确定时(如自定义 Transport)按已送达处理
+func (c *Client) doRequest(ctx context.Context, r *Request, jsonBody []byte) (resp *http.Response, sent bool, err error) {
+	var reqBody io.Reader
+	if jsonBody != nil {
+		reqBody = bytes.NewReader(jsonBody)
+	}
+
+	var gotConn, wrote atomic.Bool
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn:      func(httptrace.GotConnInfo) { gotConn.Store(true) },
+		WroteRequest: func(httptrace.WroteRequestInfo) { wrote.Store(true) },
+	})
+	req, err := http.NewRequestWithContext(ctx, r.Method, r.Url, reqBody)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	req.Header.Set("Authorization", "Bearer "+c.apiKey)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
+	for key, values := range r.Header {
+		req.Header[key] = values
+	}
+
+	resp, err = c.httpClient.Do(req)
+	return resp, !gotConn.Load() || wrote.Load(), err
+}
+
+// maxErrorBodySize 读取错误响应体的最大长度
+const maxErrorBodySize = 1 << 20
 
-	return c.httpClient.Do(req)
+// newResponseError 读取非200响应体并解析为 APIError
+func newResponseError(resp *http.Response, op string) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	return errors.NewAPIError(op, resp.StatusCode, resp.Status, resp.Header, body)
 }
@@ -1,27 +1,26 @@
 package engine
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
-	"log"
 	"net/http"
-	"strings"
+	"time"
 
 	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/completion"
 	"github.com/miajio/dpsk/errors"
 	"github.com/miajio/dpsk/model"
 )
 
 // GetModels 获取模型列表
 func (c *Client) GetModels(ctx context.Context) (*model.ModelList, error) {
-	resp, err := c.makeRequest(ctx, http.MethodGet, c.apiUrl+c.urlMap["models"], nil)
+	resp, err := c.makeRequest(ctx, OperationModels, http.MethodGet, c.apiUrl+c.urlMap["models"], nil)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, errors.NewCodeErrorF(resp.StatusCode, "failed to get models: %s", resp.Status)
+		return nil, newResponseError(resp, "failed to get models")
 	}
 
 	var modelList model.ModelList
@@ -33,13 +32,13 @@ func (c *Client) GetModels(ctx context.Context) (*model.ModelList, error) {
 
 // GetBalance 获取账户余额
 func (c *Client) GetBalance(ctx context.Context) (*model.Balance, error) {
-	resp, err := c.makeRequest(ctx, http.MethodGet, c.apiUrl+c.urlMap["balance"], nil)
+	resp, err := c.makeRequest(ctx, OperationBalance, http.MethodGet, c.apiUrl+c.urlMap["balance"], nil)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, errors.NewCodeErrorF(resp.StatusCode, "failed to get balance: %s", resp.Status)
+		return nil, newResponseError(resp, "failed to get balance")
 	}
 
 	var balance model.Balance
@@ -54,69 +53,237 @@ func (c *Client) Chat(ctx context.Context, req *chat.ChatRequest) (*chat.ChatRes
 	if req.Stream {
 		return nil, errors.NewCodeError(http.StatusBadRequest, "streaming is not supported, use ChatStream instead")
 	}
-	resp, err := c.makeRequest(ctx, http.MethodPost, c.apiUrl+c.urlMap["chat"], req)
+	req, err := c.checkModel(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	cacheKey, cached := c.cache.lookup(ctx, req)
+	if cached != nil {
+		c.logCache(ctx, OperationChat, req.Model)
+		return cached, nil
+	}
+	settle, err := c.reserveBudget(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	var usage chat.Usage
+	defer func() { settle(&usage) }()
+
+	start := time.Now()
+	resp, err := c.makeRequest(ctx, OperationChat, http.MethodPost, c.chatUrl(req), req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, errors.NewCodeErrorF(resp.StatusCode, "failed to chat: %s", resp.Status)
+		return nil, newResponseError(resp, "failed to chat")
 	}
 	var completion chat.ChatResponse
 	if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil {
 		return nil, err
 	}
+	usage = completion.Usage
+	c.recordUsage(ctx, req.Model, completion.Usage, start)
+	c.cache.save(cacheKey, &completion)
 	return &completion, nil
 }
 
-// ChatStream 发送流式请求
-func (c *Client) ChatStream(ctx context.Context, req *chat.ChatRequest) (<-chan chat.ChatResponse, <-chan error, error) {
+// ChatPrefix 对话前缀续写(beta), 模型从最后一条 assistant 前缀消息开始续写
+// 请求发送到 beta 接口, 返回的 PrefixResponse 可分别获取续写部分或与前缀拼接的完整内容
+// 流式续写可直接使用 ChatStream, 前缀续写请求会自动发送到 beta 接口
+func (c *Client) ChatPrefix(ctx context.Context, req *chat.ChatRequest) (*chat.PrefixResponse, error) {
+	if err := req.ValidatePrefix(); err != nil {
+		return nil, err
+	}
+	resp, err := c.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	prefix, _ := req.PrefixMessage()
+	return &chat.PrefixResponse{ChatResponse: resp, Prefix: prefix}, nil
+}
+
+// chatUrl 返回对话接口地址, 前缀续写请求使用 beta 接口
+func (c *Client) chatUrl(req *chat.ChatRequest) string {
+	if req.IsPrefixCompletion() {
+		return c.betaUrl + c.urlMap["chat"]
+	}
+	return c.apiUrl + c.urlMap["chat"]
+}
+
+// ChatStream 发送流式请求, 返回的 Stream 使用完毕后需要调用 Close
+func (c *Client) ChatStream(ctx context.Context, req *chat.ChatRequest) (*Stream[chat.ChatResponse], error) {
 	if !req.Stream {
-		return nil, nil, errors.NewCodeError(http.StatusBadRequest, "stream is not enabled")
+		return nil, errors.NewCodeError(http.StatusBadRequest, "stream is not enabled")
+	}
+	req, err := c.checkModel(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	cacheKey, cached := c.cache.lookup(ctx, req)
+	if cached != nil {
+		c.logCache(ctx, OperationChatStream, req.Model)
+		return cachedStream(ctx, req, cached), nil
 	}
 
-	resp, err := c.makeRequest(ctx, http.MethodPost, c.apiUrl+c.urlMap["chat"], req)
+	settle, err := c.reserveBudget(ctx, req)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := c.makeRequest(ctx, OperationChatStream, http.MethodPost, c.chatUrl(req), req)
+	if err != nil {
+		settle(&chat.Usage{})
+		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, nil, errors.NewCodeErrorF(resp.StatusCode, "failed to chat stream: %s", resp.Status)
+		defer resp.Body.Close()
+		settle(&chat.Usage{})
+		return nil, newResponseError(resp, "failed to chat stream")
+	}
+	var usage *chat.Usage
+	var acc *chat.StreamAccumulator
+	if cacheKey != "" {
+		acc = chat.NewStreamAccumulator()
+	}
+	chunks := 0
+	stream := newStream(ctx, resp, func(chunk chat.ChatResponse) {
+		chunks++
+		if chunk.Usage.TotalTokens > 0 {
+			usage = &chunk.Usage
+		}
+		if acc != nil {
+			acc.Add(chunk)
+		}
+		c.recordUsage(ctx, req.Model, chunk.Usage, start)
+	})
+	stream.onClose = func() {
+		// 未获取到用量时无法得知实际费用, 按估算费用结算
+		settle(usage)
+		c.logStream(ctx, OperationChatStream, req.Model, chunks, stream.err, start)
+		// 仅缓存完整读取且带有用量的流, 否则回放时无法提供 include_usage 所需的用量
+		if acc != nil && stream.done && stream.err == nil && usage != nil {
+			c.cache.save(cacheKey, acc.Response())
+		}
+	}
+	return stream, nil
+}
+
+// ChatStreamCollect 发送流式请求并合并所有响应块, 返回与 Chat 相同结构的完整响应
+func (c *Client) ChatStreamCollect(ctx context.Context, req *chat.ChatRequest) (*chat.ChatResponse, error) {
+	stream, err := c.ChatStream(ctx, req)
+	if err != nil {
+		return nil, err
 	}
+	defer stream.Close()
 
-	errChan := make(chan error, 1)
-	resChain := make(chan chat.ChatResponse)
+	acc := chat.NewStreamAccumulator()
+	for stream.Next() {
+		acc.Add(stream.Current())
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+	return acc.Response(), nil
+}
 
-	go func() {
+// Complete FIM补全(beta), 根据前缀与后缀补全中间内容
+func (c *Client) Complete(ctx context.Context, req *completion.CompletionRequest) (*completion.CompletionResponse, error) {
+	if req.Stream {
+		return nil, errors.NewCodeError(http.StatusBadRequest, "streaming is not supported, use CompleteStream instead")
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if err := c.checkCompletionModel(ctx, req); err != nil {
+		return nil, err
+	}
+	settle, err := c.reserveCompletionBudget(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	var usage chat.Usage
+	defer func() { settle(&usage) }()
+
+	start := time.Now()
+	resp, err := c.makeRequest(ctx, OperationCompletion, http.MethodPost, c.betaUrl+c.urlMap["completions"], req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, newResponseError(resp, "failed to complete")
+	}
+	var result completion.CompletionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	usage = result.Usage
+	c.recordUsage(ctx, req.Model, result.Usage, start)
+	return &result, nil
+}
+
+// CompleteStream 发送流式FIM补全请求(beta), 返回的 Stream 使用完毕后需要调用 Close
+func (c *Client) CompleteStream(ctx context.Context, req *completion.CompletionRequest) (*Stream[completion.CompletionResponse], error) {
+	if !req.Stream {
+		return nil, errors.NewCodeError(http.StatusBadRequest, "stream is not enabled")
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if err := c.checkCompletionModel(ctx, req); err != nil {
+		return nil, err
+	}
+	settle, err := c.reserveCompletionBudget(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := c.makeRequest(ctx, OperationCompletionStream, http.MethodPost, c.betaUrl+c.urlMap["completions"], req)
+	if err != nil {
+		settle(&chat.Usage{})
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
 		defer resp.Body.Close()
-		defer close(resChain)
-		defer close(errChan)
-		scanner := bufio.NewScanner(resp.Body)
-		for scanner.Scan() {
-			line := scanner.Text()
-			if strings.TrimSpace(line) == "" {
-				continue
-			}
-			if line == "data: [DONE]" {
-				return
-			}
-			if !strings.HasPrefix(line, "data: ") {
-				errChan <- errors.NewCodeErrorF(http.StatusBadRequest, "invalid response: %s", line)
-				continue
-			}
-
-			jsonData := strings.TrimPrefix(line, "data: ")
-			var event chat.ChatResponse
-			if err := json.Unmarshal([]byte(jsonData), &event); err != nil {
-				log.Printf("failed to parse response: %s, error: %v", jsonData, err)
-				errChan <- errors.NewCodeErrorF(http.StatusBadRequest, "failed to parse response: %v", err)
-				continue
-			}
-			resChain <- event
-		}
-		if err := scanner.Err(); err != nil {
-			errChan <- errors.NewCodeErrorF(http.StatusBadRequest, "scanner error: %v", err)
+		settle(&chat.Usage{})
+		return nil, newResponseError(resp, "failed to complete stream")
+	}
+	var usage *chat.Usage
+	chunks := 0
+	stream := newStream(ctx, resp, func(chunk completion.CompletionResponse) {
+		chunks++
+		if chunk.Usage.TotalTokens > 0 {
+			usage = &chunk.Usage
 		}
-	}()
-	return resChain, errChan, nil
+		c.recordUsage(ctx, req.Model, chunk.Usage, start)
+	})
+	stream.onClose = func() {
+		c.logStream(ctx, OperationCompletionStream, req.Model, chunks, stream.err, start)
+		// 未获取到用量时按估算费用结算
+		settle(usage)
+	}
+	return stream, nil
+}
+
+// CompleteStreamCollect 发送流式FIM补全请求并合并所有响应块, 返回与 Complete 相同结构的完整响应
+func (c *Client) CompleteStreamCollect(ctx context.Context, req *completion.CompletionRequest) (*completion.CompletionResponse, error) {
+	stream, err := c.CompleteStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	acc := completion.NewStreamAccumulator()
+	for stream.Next() {
+		acc.Add(stream.Current())
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+	return acc.Response(), nil
 }
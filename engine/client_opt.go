@@ -1,8 +1,12 @@
 package engine
 
 import (
+	"log/slog"
 	"net/http"
 	"time"
+
+	"github.com/miajio/dpsk/cache"
+	"github.com/miajio/dpsk/pricing"
 )
 
 type Option func(*Client)
@@ -28,6 +32,20 @@ func WithBalanceUrl(balanceUrl string) Option {
 	}
 }
 
+// WithBetaUrl 设置beta接口地址, 默认 https://api.deepseek.com/beta
+func WithBetaUrl(betaUrl string) Option {
+	return func(c *Client) {
+		c.betaUrl = betaUrl
+	}
+}
+
+// WithCompletionsUrl 设置FIM补全url, 相对于beta接口地址
+func WithCompletionsUrl(completionsUrl string) Option {
+	return func(c *Client) {
+		c.urlMap["completions"] = completionsUrl
+	}
+}
+
 // WithChatCompletionsUrl 设置对话url
 func WithChatCompletionsUrl(chatCompletionsUrl string) Option {
 	return func(c *Client) {
@@ -55,3 +73,74 @@ func WithHttpClient(httpClient *http.Client) Option {
 		c.httpClient = httpClient
 	}
 }
+
+// WithRetry 设置重试策略, 对 GetModels、GetBalance、Chat 及 ChatStream 的建立连接阶段生效
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retryPolicy = &policy
+	}
+}
+
+// WithRateLimit 设置客户端限流(每分钟请求数、每分钟token数、最大并发数)
+// 等待许可时遵循 ctx 的取消与超时
+func WithRateLimit(rateLimit RateLimit) Option {
+	return func(c *Client) {
+		c.limiter = newLimiter(rateLimit)
+	}
+}
+
+// WithMiddleware 添加中间件, 按添加顺序由外到内执行
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(c *Client) {
+		c.middlewares = append(c.middlewares, middlewares...)
+	}
+}
+
+// WithCostTracker 设置花费累计器, Chat 与 ChatStream 的用量会按 ctx 中的标签计入
+// 流式请求需开启 StreamOptions.IncludeUsage 才能获取用量; 价格表中没有的模型不计入
+func WithCostTracker(tracker *pricing.Tracker) Option {
+	return func(c *Client) {
+		c.costTracker = tracker
+	}
+}
+
+// WithBudget 设置预算守卫, Chat、ChatStream、Complete 与 CompleteStream 在发送前估算费用
+// 超出客户端总预算、每日预算、标签预算或余额下限时返回 *ErrBudgetExceeded, 不会发出请求
+func WithBudget(budget Budget) Option {
+	return func(c *Client) {
+		c.budget = newBudgetGuard(c, budget)
+	}
+}
+
+// WithModelRegistry 设置模型列表缓存时间与不支持参数的处理方式
+// Chat 与 ChatStream 发送前按模型能力拒绝或剔除不支持的参数, ttl 为0时默认缓存1小时
+func WithModelRegistry(ttl time.Duration, policy ParamPolicy) Option {
+	return func(c *Client) {
+		c.registryTTL = ttl
+		c.paramPolicy = policy
+	}
+}
+
+// WithCache 设置对话响应缓存, Chat 与 ChatStream 对相同请求直接返回缓存的响应, 不会发出请求
+// 缓存命中不计入花费与预算, ChatStream 命中时将缓存的响应回放为流式响应
+// 可通过 ContextWithCacheMode 为单次请求跳过或刷新缓存
+func WithCache(store cache.Store) Option {
+	return func(c *Client) {
+		c.cache = &responseCache{store: store}
+	}
+}
+
+// WithLogger 设置结构化日志, 记录请求开始与结束、状态码、耗时、重试、流式读取错误与用量
+// 日志中出现的 apiKey 自动替换为 [REDACTED]; 请求体仅在 Debug 级别输出
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// WithLogRedactContent 设置日志中的消息内容、思维链、工具参数与补全前后缀是否替换为 [REDACTED]
+func WithLogRedactContent(redact bool) Option {
+	return func(c *Client) {
+		c.logRedactContent = redact
+	}
+}
@@ -0,0 +1,34 @@
+package engine
+
+import (
+	"context"
+	"time"
+
+	"github.com/miajio/dpsk/chat"
+)
+
+// tagKey 统计标签的 context key
+type tagKey struct{}
+
+// ContextWithTag 为请求设置统计标签(如业务名、会话ID), 用于按标签统计花费
+func ContextWithTag(ctx context.Context, tag string) context.Context {
+	return context.WithValue(ctx, tagKey{}, tag)
+}
+
+// TagFromContext 读取统计标签
+func TagFromContext(ctx context.Context) string {
+	tag, _ := ctx.Value(tagKey{}).(string)
+	return tag
+}
+
+// recordUsage 记录一次请求的用量
+func (c *Client) recordUsage(ctx context.Context, model string, usage chat.Usage, at time.Time) {
+	if usage.TotalTokens == 0 {
+		return
+	}
+	c.logTokenUsage(ctx, model, usage)
+	if c.costTracker == nil {
+		return
+	}
+	c.costTracker.Record(TagFromContext(ctx), model, usage, at)
+}
@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/chat/schema"
+	"github.com/miajio/dpsk/errors"
+)
+
+// defaultJSONRetries JSON 输出校验失败后的默认重试次数
+const defaultJSONRetries = 2
+
+// JSONOption ChatJSON 选项
+type JSONOption func(*jsonOptions)
+
+// jsonOptions ChatJSON 配置
+type jsonOptions struct {
+	retries int
+	schema  *schema.Schema
+}
+
+// WithJSONRetries 设置校验失败后携带错误信息重新请求的次数, 默认2次
+func WithJSONRetries(retries int) JSONOption {
+	return func(o *jsonOptions) {
+		o.retries = retries
+	}
+}
+
+// WithJSONSchema 使用指定的 Schema 校验输出, 默认由 T 生成
+func WithJSONSchema(s *schema.Schema) JSONOption {
+	return func(o *jsonOptions) {
+		o.schema = s
+	}
+}
+
+// ChatJSON 以 JSON 输出模式发送请求, 校验输出后解码为 T
+// JSON 输出模式只生成 JSON 对象, T 或 WithJSONSchema 指定的 Schema 必须为 object 类型
+// 请求会设置 response_format 为 json_object, 并在系统消息中附带 T 的 JSON Schema 以满足接口"提示词需包含 json"的要求
+// 输出为空、被截断或不符合 Schema 时, 将错误信息发回模型重新生成, 最多重试 WithJSONRetries 次
+// req 不会被修改, 返回的响应为最后一次请求的响应
+func ChatJSON[T any](ctx context.Context, c *Client, req *chat.ChatRequest, opts ...JSONOption) (T, *chat.ChatResponse, error) {
+	var v T
+	o := jsonOptions{retries: defaultJSONRetries}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.schema == nil {
+		s, err := schema.For[T]()
+		if err != nil {
+			return v, nil, err
+		}
+		o.schema = s
+	}
+	if o.schema.Type != "object" {
+		return v, nil, errors.NewCodeErrorF(http.StatusBadRequest, "ChatJSON requires an object schema, got %q", o.schema.Type)
+	}
+	if req.Stream {
+		return v, nil, errors.NewCodeError(http.StatusBadRequest, "ChatJSON does not support streaming request")
+	}
+
+	schemaJSON, err := json.Marshal(o.schema)
+	if err != nil {
+		return v, nil, err
+	}
+	jsonReq := *req
+	jsonReq.ResponseFormat = &chat.ResponseFormat{Type: "json_object"}
+	jsonReq.Messages = withJSONInstruction(req.Messages, string(schemaJSON))
+
+	var resp *chat.ChatResponse
+	var lastErr error
+	for attempt := 0; attempt <= o.retries; attempt++ {
+		if resp, err = c.Chat(ctx, &jsonReq); err != nil {
+			return v, resp, err
+		}
+		content := resp.Content()
+		if lastErr = checkJSONOutput(resp, content, o.schema); lastErr == nil {
+			if err := json.Unmarshal([]byte(content), &v); err != nil {
+				lastErr = errors.NewCodeErrorF(http.StatusBadGateway, "failed to decode json output: %v", err)
+			} else {
+				return v, resp, nil
+			}
+		}
+		jsonReq.Messages = append(jsonReq.Messages,
+			chat.Message{Role: "assistant", Content: nonEmpty(content, "{}")},
+			chat.Message{Role: "user", Content: "上面输出的 JSON 无效: " + lastErr.Error() + "\n请修正后重新输出完整的 JSON, 不要包含其他内容。"},
+		)
+	}
+	return v, resp, errors.NewCodeErrorF(http.StatusBadGateway, "invalid json output after %d attempts: %v", o.retries+1, lastErr)
+}
+
+// withJSONInstruction 在开头的系统消息之后插入 JSON 输出说明, 返回新的消息列表
+func withJSONInstruction(msgs []chat.Message, schemaJSON string) []chat.Message {
+	instruction := chat.Message{
+		Role:    "system",
+		Content: "请仅输出一个 JSON 对象, 不要包含其他内容, 且必须符合以下 JSON Schema:\n" + schemaJSON,
+	}
+	i := 0
+	for i < len(msgs) && msgs[i].Role == "system" {
+		i++
+	}
+	result := make([]chat.Message, 0, len(msgs)+1)
+	result = append(result, msgs[:i]...)
+	result = append(result, instruction)
+	return append(result, msgs[i:]...)
+}
+
+// checkJSONOutput 检查输出是否为空、为 null、被截断以及是否符合 Schema
+func checkJSONOutput(resp *chat.ChatResponse, content string, s *schema.Schema) error {
+	if len(resp.Choices) > 0 && resp.Choices[0].FinishReason == "length" {
+		return errors.NewCodeError(http.StatusBadGateway, "json output is truncated, max_tokens may be too small")
+	}
+	switch strings.TrimSpace(content) {
+	case "":
+		return errors.NewCodeError(http.StatusBadGateway, "json output is empty")
+	case "null":
+		// T 为指针时 Schema 允许 null, 但解码后会得到 nil
+		return errors.NewCodeError(http.StatusBadGateway, "json output is null")
+	}
+	return s.Validate([]byte(content))
+}
+
+// nonEmpty s 为空时返回 fallback
+func nonEmpty(s, fallback string) string {
+	if strings.TrimSpace(s) == "" {
+		return fallback
+	}
+	return s
+}
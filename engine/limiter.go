@@ -0,0 +1,184 @@
+package engine
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/completion"
+	"github.com/miajio/dpsk/tokenizer"
+)
+
+// RateLimit 客户端限流配置, 同一 Client 的所有 goroutine 共享
+type RateLimit struct {
+	RequestsPerMinute int // 每分钟最大请求数, 0为不限制
+	TokensPerMinute   int // 每分钟最大token数(按请求估算), 0为不限制
+	MaxInFlight       int // 最大并发请求数, 流式请求在响应体关闭后释放, 0为不限制
+}
+
+// limiter 限流器
+type limiter struct {
+	requests *tokenBucket
+	tokens   *tokenBucket
+	inFlight chan struct{}
+}
+
+// newLimiter 根据配置创建限流器
+func newLimiter(rl RateLimit) *limiter {
+	l := &limiter{}
+	if rl.RequestsPerMinute > 0 {
+		l.requests = newTokenBucket(float64(rl.RequestsPerMinute), time.Minute)
+	}
+	if rl.TokensPerMinute > 0 {
+		l.tokens = newTokenBucket(float64(rl.TokensPerMinute), time.Minute)
+	}
+	if rl.MaxInFlight > 0 {
+		l.inFlight = make(chan struct{}, rl.MaxInFlight)
+	}
+	return l
+}
+
+// acquire 等待获取请求许可, 返回的 release 在请求结束后调用
+func (l *limiter) acquire(ctx context.Context, tokens int) (release func(), err error) {
+	if l.inFlight != nil {
+		select {
+		case l.inFlight <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	release = func() {
+		if l.inFlight != nil {
+			<-l.inFlight
+		}
+	}
+	if err := l.wait(ctx, tokens); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// wait 等待请求数与token数配额, 每次发送(含重试)都需要调用
+func (l *limiter) wait(ctx context.Context, tokens int) error {
+	if l.requests != nil {
+		if err := l.requests.wait(ctx, 1); err != nil {
+			return err
+		}
+	}
+	if l.tokens != nil && tokens > 0 {
+		if err := l.tokens.wait(ctx, float64(tokens)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tokenBucket 令牌桶, 容量为一个周期的配额, 按周期匀速补充
+type tokenBucket struct {
+	mu       sync.Mutex
+	capacity float64
+	rate     float64 // 每纳秒补充的令牌数
+	tokens   float64
+	last     time.Time
+}
+
+// newTokenBucket 创建令牌桶, 每 per 时间补充 limit 个令牌
+func newTokenBucket(limit float64, per time.Duration) *tokenBucket {
+	return &tokenBucket{
+		capacity: limit,
+		rate:     limit / float64(per),
+		tokens:   limit,
+		last:     time.Now(),
+	}
+}
+
+// reserve 预占 n 个令牌, 返回需要等待的时间
+// 超过桶容量的请求按容量计算, 避免永远无法满足
+func (b *tokenBucket) reserve(n float64) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if n > b.capacity {
+		n = b.capacity
+	}
+	now := time.Now()
+	b.tokens += float64(now.Sub(b.last)) * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate)
+}
+
+// cancel 归还预占的令牌
+func (b *tokenBucket) cancel(n float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if n > b.capacity {
+		n = b.capacity
+	}
+	b.tokens += n
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+}
+
+// wait 等待获取 n 个令牌, ctx取消时归还预占的令牌
+func (b *tokenBucket) wait(ctx context.Context, n float64) error {
+	delay := b.reserve(n)
+	if err := sleepContext(ctx, delay); err != nil {
+		b.cancel(n)
+		return err
+	}
+	return nil
+}
+
+// EstimateTokens 粗略估算请求消耗的token数: 按字符数估算输入token数, 加上 MaxTokens
+func EstimateTokens(req *chat.ChatRequest) int {
+	if req == nil {
+		return 0
+	}
+	return tokenizer.CountRequest(tokenizer.Estimator{}, req) + req.MaxTokens
+}
+
+// estimateCompletionTokens 粗略估算FIM补全请求消耗的token数: 前缀与后缀的估算token数, 加上 MaxTokens
+func estimateCompletionTokens(req *completion.CompletionRequest) int {
+	return completionInputTokens(req) + req.MaxTokens
+}
+
+// completionInputTokens 估算FIM补全请求的输入token数
+func completionInputTokens(req *completion.CompletionRequest) int {
+	estimator := tokenizer.Estimator{}
+	return tokenizer.PerRequestTokens + estimator.CountTokens(req.Prompt) + estimator.CountTokens(req.Suffix)
+}
+
+// releaseBody 响应体关闭时释放限流许可
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+// Close 关闭响应体并释放许可
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// withRelease 将许可的释放绑定到响应体的关闭
+func withRelease(resp *http.Response, err error, release func()) (*http.Response, error) {
+	if err != nil || resp == nil {
+		release()
+		return resp, err
+	}
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
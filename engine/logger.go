@@ -0,0 +1,270 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/completion"
+	"github.com/miajio/dpsk/errors"
+)
+
+// redactedValue 脱敏后的占位值
+const redactedValue = "[REDACTED]"
+
+// 日志事件
+const (
+	logRequestStart  = "dpsk request start"  // 请求开始(Debug)
+	logRequestFinish = "dpsk request finish" // 请求结束, 非2xx为 Warn, 网络错误为 Error
+	logRequestRetry  = "dpsk request retry"  // 重试(Warn)
+	logStreamFinish  = "dpsk stream finish"  // 流式响应读取结束(Debug)
+	logStreamError   = "dpsk stream error"   // 流式响应读取出错(Warn)
+	logUsage         = "dpsk usage"          // 用量(Info)
+	logCacheHit      = "dpsk cache hit"      // 响应缓存命中(Debug)
+)
+
+// logEnabled 是否输出指定级别的日志
+func (c *Client) logEnabled(ctx context.Context, level slog.Level) bool {
+	return c.logger != nil && c.logger.Enabled(ctx, level)
+}
+
+// logRequestStarted 记录请求开始, Debug 级别附带请求体
+func (c *Client) logRequestStarted(ctx context.Context, r *Request, attempt int) {
+	if !c.logEnabled(ctx, slog.LevelDebug) {
+		return
+	}
+	attrs := append(requestAttrs(r, attempt), requestBodyAttrs(r.Body, c.logRedactContent)...)
+	c.logger.LogAttrs(ctx, slog.LevelDebug, logRequestStart, attrs...)
+}
+
+// logRequestFinished 记录请求结束时的状态码与耗时
+func (c *Client) logRequestFinished(ctx context.Context, r *Request, attempt int, resp *http.Response, err error, latency time.Duration) {
+	if c.logger == nil {
+		return
+	}
+	attrs := append(requestAttrs(r, attempt), slog.Duration("latency", latency))
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, slog.String("error", err.Error()))
+	} else {
+		if resp.StatusCode >= http.StatusBadRequest {
+			level = slog.LevelWarn
+		}
+		attrs = append(attrs, slog.Int("status", resp.StatusCode))
+	}
+	c.logger.LogAttrs(ctx, level, logRequestFinish, attrs...)
+}
+
+// logRetry 记录重试
+func (c *Client) logRetry(ctx context.Context, r *Request, event RetryEvent) {
+	if c.logger == nil {
+		return
+	}
+	attrs := append(requestAttrs(r, event.Attempt), slog.Duration("delay", event.Delay))
+	if event.StatusCode != 0 {
+		attrs = append(attrs, slog.Int("status", event.StatusCode))
+	}
+	if event.Err != nil {
+		attrs = append(attrs, slog.String("error", event.Err.Error()))
+	}
+	c.logger.LogAttrs(ctx, slog.LevelWarn, logRequestRetry, attrs...)
+}
+
+// logStream 记录流式响应读取结束, 出错时为 Warn 级别, 错误事件只记录解析后的字段
+func (c *Client) logStream(ctx context.Context, op Operation, model string, chunks int, err error, start time.Time) {
+	if c.logger == nil {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("op", string(op)),
+		slog.String("model", model),
+		slog.Int("chunks", chunks),
+		slog.Duration("latency", time.Since(start)),
+	}
+	if err != nil {
+		c.logger.LogAttrs(ctx, slog.LevelWarn, logStreamError, append(attrs, streamErrorAttr(err))...)
+		return
+	}
+	c.logger.LogAttrs(ctx, slog.LevelDebug, logStreamFinish, attrs...)
+}
+
+// streamErrorAttr 流式响应错误的日志字段, APIError 不记录原始数据
+func streamErrorAttr(err error) slog.Attr {
+	apiErr := errors.ReadAPIError(err)
+	if apiErr == nil {
+		return slog.String("error", err.Error())
+	}
+	attrs := []any{slog.Int("code", apiErr.Code), slog.String("message", apiErr.Message)}
+	if apiErr.Type != "" {
+		attrs = append(attrs, slog.String("type", apiErr.Type))
+	}
+	if apiErr.ErrCode != "" {
+		attrs = append(attrs, slog.String("err_code", apiErr.ErrCode))
+	}
+	if apiErr.RequestID != "" {
+		attrs = append(attrs, slog.String("request_id", apiErr.RequestID))
+	}
+	return slog.Group("error", attrs...)
+}
+
+// logTokenUsage 记录一次请求的用量
+func (c *Client) logTokenUsage(ctx context.Context, model string, usage chat.Usage) {
+	if c.logger == nil {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("model", model),
+		slog.Int("prompt_tokens", usage.PromptTokens),
+		slog.Int("completion_tokens", usage.CompletionTokens),
+		slog.Int("total_tokens", usage.TotalTokens),
+		slog.Int("prompt_cache_hit_tokens", usage.PromptCacheHitTokens),
+	}
+	if usage.CompletionTokensDetails.ReasoningTokens > 0 {
+		attrs = append(attrs, slog.Int("reasoning_tokens", usage.CompletionTokensDetails.ReasoningTokens))
+	}
+	if tag := TagFromContext(ctx); tag != "" {
+		attrs = append(attrs, slog.String("tag", tag))
+	}
+	c.logger.LogAttrs(ctx, slog.LevelInfo, logUsage, attrs...)
+}
+
+// logCache 记录响应缓存命中
+func (c *Client) logCache(ctx context.Context, op Operation, model string) {
+	if c.logger == nil {
+		return
+	}
+	c.logger.LogAttrs(ctx, slog.LevelDebug, logCacheHit, slog.String("op", string(op)), slog.String("model", model))
+}
+
+// requestAttrs 请求的公共日志字段
+func requestAttrs(r *Request, attempt int) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("op", string(r.Operation)),
+		slog.String("method", r.Method),
+		slog.String("url", r.Url),
+		slog.Int("attempt", attempt),
+	}
+	switch body := r.Body.(type) {
+	case *chat.ChatRequest:
+		attrs = append(attrs, slog.String("model", body.Model))
+	case *completion.CompletionRequest:
+		attrs = append(attrs, slog.String("model", body.Model))
+	}
+	return attrs
+}
+
+// requestBodyAttrs 请求体日志字段, redactContent 为 true 时消息内容、思维链、工具参数与补全前后缀替换为占位值
+func requestBodyAttrs(body any, redactContent bool) []slog.Attr {
+	if body == nil {
+		return nil
+	}
+	if redactContent {
+		switch b := body.(type) {
+		case *chat.ChatRequest:
+			body = redactChatRequest(b)
+		case *completion.CompletionRequest:
+			r := *b
+			r.Prompt = redactString(r.Prompt)
+			r.Suffix = redactString(r.Suffix)
+			body = &r
+		}
+	}
+	return []slog.Attr{slog.Any("body", body)}
+}
+
+// redactChatRequest 返回消息内容脱敏后的请求副本
+func redactChatRequest(req *chat.ChatRequest) *chat.ChatRequest {
+	r := *req
+	r.Messages = make([]chat.Message, len(req.Messages))
+	for i, msg := range req.Messages {
+		msg.Content = redactString(msg.Content)
+		msg.ReasoningContent = redactString(msg.ReasoningContent)
+		if len(msg.ToolCalls) > 0 {
+			calls := make([]chat.ToolCall, len(msg.ToolCalls))
+			for j, call := range msg.ToolCalls {
+				call.Function.Arguments = redactString(call.Function.Arguments)
+				calls[j] = call
+			}
+			msg.ToolCalls = calls
+		}
+		r.Messages[i] = msg
+	}
+	return &r
+}
+
+// redactString 非空字符串替换为占位值, 保留长度便于排查
+func redactString(s string) string {
+	if s == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s(%d)", redactedValue, len(s))
+}
+
+// redactHandler 将日志中出现的 apiKey 替换为占位值
+// apiKey 在输出时读取, 创建客户端后通过 WithApiKey 修改的 apiKey 同样会被脱敏
+type redactHandler struct {
+	slog.Handler
+	secret func() string
+}
+
+// Handle 脱敏后交给下层处理器
+func (h *redactHandler) Handle(ctx context.Context, record slog.Record) error {
+	secret := h.secret()
+	if secret == "" {
+		return h.Handler.Handle(ctx, record)
+	}
+	redacted := slog.NewRecord(record.Time, record.Level, redact(record.Message, secret), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(attr, secret))
+		return true
+	})
+	return h.Handler.Handle(ctx, redacted)
+}
+
+// WithAttrs 实现 slog.Handler
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if secret := h.secret(); secret != "" {
+		redacted := make([]slog.Attr, len(attrs))
+		for i, attr := range attrs {
+			redacted[i] = redactAttr(attr, secret)
+		}
+		attrs = redacted
+	}
+	return &redactHandler{Handler: h.Handler.WithAttrs(attrs), secret: h.secret}
+}
+
+// WithGroup 实现 slog.Handler
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{Handler: h.Handler.WithGroup(name), secret: h.secret}
+}
+
+// redactAttr 脱敏字段值, 非字符串值按格式化后的文本判断
+func redactAttr(attr slog.Attr, secret string) slog.Attr {
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		attr.Value = slog.StringValue(redact(value.String(), secret))
+	case slog.KindGroup:
+		group := value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, a := range group {
+			redacted[i] = redactAttr(a, secret)
+		}
+		attr.Value = slog.GroupValue(redacted...)
+	case slog.KindAny:
+		if text := fmt.Sprintf("%+v", value.Any()); strings.Contains(text, secret) {
+			attr.Value = slog.StringValue(redact(text, secret))
+		}
+	}
+	return attr
+}
+
+// redact 替换字符串中的 apiKey
+func redact(s, secret string) string {
+	return strings.ReplaceAll(s, secret, redactedValue)
+}
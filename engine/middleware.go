@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+)
+
+// Operation 请求操作类型
+type Operation string
+
+const (
+	OperationModels     Operation = "models"      // 模型列表
+	OperationBalance    Operation = "balance"     // 余额查询
+	OperationChat       Operation = "chat"        // 对话
+	OperationChatStream Operation = "chat-stream" // 流式对话
+
+	OperationCompletion       Operation = "completion"        // FIM补全
+	OperationCompletionStream Operation = "completion-stream" // 流式FIM补全
+)
+
+// Request 经过中间件链的请求
+type Request struct {
+	Operation Operation   // 操作类型
+	Method    string      // 请求方法
+	Url       string      // 请求地址
+	Header    http.Header // 附加请求头, 会覆盖默认请求头
+	Body      any         // 请求体, 如 *chat.ChatRequest、*completion.CompletionRequest, 在中间件链末端序列化为JSON
+}
+
+// Handler 请求处理函数
+type Handler func(ctx context.Context, req *Request) (*http.Response, error)
+
+// Middleware 中间件, 可在请求前后执行逻辑, 修改请求或直接返回响应
+type Middleware func(next Handler) Handler
+
+// chainMiddlewares 组装中间件链, 第一个中间件位于最外层
+func chainMiddlewares(handler Handler, middlewares []Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// HeaderMiddleware 为每个请求添加请求头
+func HeaderMiddleware(header http.Header) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) (*http.Response, error) {
+			if req.Header == nil {
+				req.Header = make(http.Header)
+			}
+			for key, values := range header {
+				req.Header[key] = append([]string(nil), values...)
+			}
+			return next(ctx, req)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/completion"
+	"github.com/miajio/dpsk/errors"
+	"github.com/miajio/dpsk/model"
+)
+
+// defaultRegistryTTL 模型列表的默认缓存时间
+const defaultRegistryTTL = time.Hour
+
+// ParamPolicy 请求包含模型不支持的参数时的处理方式
+type ParamPolicy int
+
+const (
+	ParamPolicyNone   ParamPolicy = iota // 不校验
+	ParamPolicyReject                    // 拒绝请求
+	ParamPolicyStrip                     // 剔除不支持的参数后发送
+)
+
+// Registry 模型注册表, 合并 GetModels 返回的模型列表与内置能力表, 按 TTL 缓存
+type Registry struct {
+	client *Client
+	ttl    time.Duration
+
+	mu      sync.Mutex
+	models  map[string]model.Capabilities
+	order   []string
+	fetched time.Time
+}
+
+// newRegistry 创建模型注册表
+func newRegistry(c *Client, ttl time.Duration) *Registry {
+	if ttl <= 0 {
+		ttl = defaultRegistryTTL
+	}
+	return &Registry{client: c, ttl: ttl}
+}
+
+// refresh 缓存过期时重新获取模型列表, 获取失败时沿用旧的缓存
+func (r *Registry) refresh(ctx context.Context) error {
+	r.mu.Lock()
+	fresh := r.models != nil && time.Since(r.fetched) < r.ttl
+	r.mu.Unlock()
+	if fresh {
+		return nil
+	}
+
+	list, err := r.client.GetModels(ctx)
+	if err != nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.models != nil {
+			return nil
+		}
+		return err
+	}
+
+	models := make(map[string]model.Capabilities, len(list.Data))
+	order := make([]string, 0, len(list.Data))
+	for _, m := range list.Data {
+		caps, _ := model.LookupCapabilities(m.ID)
+		if m.OwnedBy != "" {
+			caps.OwnedBy = m.OwnedBy
+		}
+		models[m.ID] = caps
+		order = append(order, m.ID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.models = models
+	r.order = order
+	r.fetched = time.Now()
+	return nil
+}
+
+// List 返回当前可用的模型及其能力
+func (r *Registry) List(ctx context.Context) ([]model.Capabilities, error) {
+	if err := r.refresh(ctx); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	caps := make([]model.Capabilities, 0, len(r.order))
+	for _, id := range r.order {
+		caps = append(caps, r.models[id])
+	}
+	return caps, nil
+}
+
+// Get 返回模型能力, 模型不在可用列表中时返回 404 错误
+// 获取模型列表失败且没有缓存时, 内置能力表中的模型仍可返回
+func (r *Registry) Get(ctx context.Context, id string) (model.Capabilities, error) {
+	if err := r.refresh(ctx); err != nil {
+		if caps, ok := model.LookupCapabilities(id); ok {
+			return caps, nil
+		}
+		return model.Capabilities{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	caps, ok := r.models[id]
+	if !ok {
+		return model.Capabilities{}, errors.NewCodeErrorF(http.StatusNotFound, "model %s is not available", id)
+	}
+	return caps, nil
+}
+
+// Invalidate 清空缓存, 下次查询时重新获取模型列表
+func (r *Registry) Invalidate() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.models = nil
+	r.order = nil
+}
+
+// Registry 返回客户端的模型注册表
+func (c *Client) Registry() *Registry {
+	return c.registry
+}
+
+// checkModel 按参数策略校验请求, 剔除参数时返回请求的副本, 不修改调用方的请求
+func (c *Client) checkModel(ctx context.Context, req *chat.ChatRequest) (*chat.ChatRequest, error) {
+	if c.paramPolicy == ParamPolicyNone {
+		return req, nil
+	}
+	caps, err := c.registry.Get(ctx, req.Model)
+	if err != nil {
+		return nil, err
+	}
+	if c.paramPolicy == ParamPolicyReject {
+		return req, req.ValidateFor(caps)
+	}
+	stripped := *req
+	if _, err := stripped.StripUnsupported(caps); err != nil {
+		return nil, err
+	}
+	return &stripped, nil
+}
+
+// checkCompletionModel 按模型能力校验FIM补全请求, 未开启模型注册表时不校验
+// FIM 无法通过剔除参数满足, 两种策略下均拒绝不支持 FIM 的模型
+func (c *Client) checkCompletionModel(ctx context.Context, req *completion.CompletionRequest) error {
+	if c.paramPolicy == ParamPolicyNone {
+		return nil
+	}
+	caps, err := c.registry.Get(ctx, req.Model)
+	if err != nil {
+		return err
+	}
+	if caps.Known && !caps.FIM {
+		return errors.NewCodeErrorF(http.StatusBadRequest, "model %s does not support FIM completion", req.Model)
+	}
+	return nil
+}
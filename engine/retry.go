@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"context"
+	stderrors "errors"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryEvent 重试事件, 在每次重试等待前回调
+type RetryEvent struct {
+	Attempt    int           // 即将进行的尝试次数(从2开始)
+	Delay      time.Duration // 本次重试前的等待时间
+	StatusCode int           // 上一次请求的HTTP状态码, 网络错误时为0
+	Err        error         // 上一次请求的网络错误
+	Method     string        // 请求方法
+	Url        string        // 请求地址
+}
+
+// RetryPolicy 重试策略
+// 服务端返回 Retry-After 且大于退避时间时, 以 Retry-After 为准
+type RetryPolicy struct {
+	MaxAttempts     int                    // 最大尝试次数(含首次请求), 小于等于1时不重试
+	BaseDelay       time.Duration          // 首次重试的基础等待时间, 之后按指数增长
+	MaxDelay        time.Duration          // 单次等待时间上限
+	Jitter          float64                // 抖动比例(0-1), 等待时间在 [delay*(1-Jitter), delay] 之间随机
+	RetryableStatus []int                  // 需要重试的HTTP状态码
+	OnRetry         func(event RetryEvent) // 重试回调, 可用于记录日志
+
+	// RetryUnsafeErrors 对请求可能已送达服务端的网络错误(如读取超时、连接重置)也进行重试
+	// 默认仅重试 GET 请求及确定未送达的错误(如建立连接失败), 开启后对话与补全请求可能被重复计费
+	RetryUnsafeErrors bool
+}
+
+// DefaultRetryPolicy 默认重试策略
+// 最多尝试3次, 对 429/500/502/503 及连接错误进行重试
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    10 * time.Second,
+		Jitter:      0.2,
+		RetryableStatus: []int{
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+		},
+	}
+}
+
+// retryable 判断状态码是否需要重试
+func (p *RetryPolicy) retryable(statusCode int) bool {
+	for _, code := range p.RetryableStatus {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// retryableError 判断网络错误是否需要重试
+func (p *RetryPolicy) retryableError(method string, sent bool, err error) bool {
+	if p.RetryUnsafeErrors || method == http.MethodGet || !sent {
+		return true
+	}
+	var opErr *net.OpError
+	if stderrors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return stderrors.As(err, &dnsErr)
+}
+
+// backoff 计算第 attempt 次重试(从1开始)的等待时间
+func (p *RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	delay := time.Duration(float64(p.BaseDelay) * math.Pow(2, float64(attempt-1)))
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if p.Jitter > 0 && delay > 0 {
+		delay -= time.Duration(rand.Float64() * p.Jitter * float64(delay))
+	}
+	if resp != nil {
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok && retryAfter > delay {
+			delay = retryAfter
+		}
+	}
+	return delay
+}
+
+// parseRetryAfter 解析 Retry-After 响应头, 支持秒数与HTTP日期两种格式
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		delay := time.Until(at)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+// sleepContext 等待指定时间, ctx取消时提前返回
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// discardBody 读取并关闭响应体, 以便连接复用
+func discardBody(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBodySize))
+	resp.Body.Close()
+}
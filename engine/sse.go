@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// SSEEvent 服务端推送事件(Server-Sent Events)
+type SSEEvent struct {
+	Event string // 事件类型, 未指定时为空(即默认的 "message")
+	ID    string // 事件ID, 未指定时沿用上一个事件的ID
+	Data  string // 事件数据, 多行 data 字段以 "\n" 连接
+	Retry int    // 服务端建议的重连间隔(毫秒), 未指定时为0
+}
+
+// SSEReader 按 HTML Living Standard 解析 text/event-stream
+// 支持注释行(如 ": keep-alive")、CRLF/LF/CR 换行、多行 data 字段与任意长度的事件
+type SSEReader struct {
+	r         *bufio.Reader
+	lastID    string
+	pendingCR bool // 上一行以 CR 结尾, 下一个字节若为 LF 则属于同一个换行符
+}
+
+// NewSSEReader 创建SSE读取器
+func NewSSEReader(r io.Reader) *SSEReader {
+	return &SSEReader{r: bufio.NewReader(r)}
+}
+
+// Next 读取下一个事件, 流结束时返回 io.EOF
+// 仅包含注释或没有 data 字段的事件会被跳过, 流结束时未以空行结尾的事件会被丢弃
+func (r *SSEReader) Next() (*SSEEvent, error) {
+	var (
+		event   SSEEvent
+		data    strings.Builder
+		hasData bool
+	)
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+
+		if line == "" {
+			if !hasData {
+				event = SSEEvent{}
+				continue
+			}
+			event.ID = r.lastID
+			event.Data = strings.TrimSuffix(data.String(), "\n")
+			return &event, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, found := strings.Cut(line, ":")
+		if found {
+			value = strings.TrimPrefix(value, " ")
+		}
+		switch field {
+		case "event":
+			event.Event = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				r.lastID = value
+			}
+		case "retry":
+			if retry, err := strconv.Atoi(value); err == nil && retry >= 0 {
+				event.Retry = retry
+			}
+		}
+	}
+}
+
+// readLine 读取一行, 支持 CRLF、LF 与 CR 三种换行符, 行长度不受限制
+// 遇到 CR 时立即返回该行, 不等待下一个字节, 避免仅以 CR 换行的服务端的事件被延迟到下一次发送
+func (r *SSEReader) readLine() (string, error) {
+	var line []byte
+	for {
+		b, err := r.r.ReadByte()
+		if err != nil {
+			// 最后一行没有换行符时, 该行属于不完整的事件, 一并丢弃
+			return "", err
+		}
+		if r.pendingCR {
+			r.pendingCR = false
+			if b == '\n' {
+				continue
+			}
+		}
+		switch b {
+		case '\n':
+			return string(line), nil
+		case '\r':
+			r.pendingCR = true
+			return string(line), nil
+		}
+		line = append(line, b)
+	}
+}
@@ -0,0 +1,176 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"iter"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/miajio/dpsk/errors"
+)
+
+// Stream 流式响应读取器
+// 通过 Next 逐个读取响应块, 读取结束或出错后返回 false; 使用完毕后必须调用 Close 关闭响应体
+// ctx 取消时会自动关闭响应体, Stream 不启动任何后台 goroutine
+// Close 可在其他 goroutine 中调用以中断正在进行的 Next
+type Stream[T any] struct {
+	ctx    context.Context
+	resp   *http.Response
+	reader *SSEReader
+	stop   func() bool
+
+	observe func(T) // 每读取到一个响应块时回调, 在 Next 中执行
+	onClose func()  // 关闭时回调, 与 Next 互斥执行且只执行一次
+
+	mu       sync.Mutex // 保护读取状态, Next 执行期间持有
+	current  T
+	err      error
+	done     bool
+	notified bool // onClose 是否已执行
+
+	closed    atomic.Bool
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// newStream 基于响应体创建流式读取器, observe 可为空
+func newStream[T any](ctx context.Context, resp *http.Response, observe func(T)) *Stream[T] {
+	s := &Stream[T]{
+		ctx:     ctx,
+		resp:    resp,
+		reader:  NewSSEReader(resp.Body),
+		observe: observe,
+	}
+	s.stop = context.AfterFunc(ctx, func() {
+		s.resp.Body.Close()
+	})
+	return s
+}
+
+// Next 读取下一个响应块, 成功时返回 true, 可通过 Current 获取
+// 流结束或出错时返回 false, 出错原因通过 Err 获取
+func (s *Stream[T]) Next() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done || s.closed.Load() {
+		return false
+	}
+	for {
+		event, err := s.reader.Next()
+		if err == io.EOF {
+			return s.finish(nil)
+		}
+		if err != nil {
+			return s.finish(errors.NewCodeErrorF(http.StatusBadGateway, "failed to read stream: %v", err))
+		}
+		if event.Data == "[DONE]" {
+			return s.finish(nil)
+		}
+		if event.Data == "" && event.Event != "error" {
+			// 空的 data 事件不包含响应块, 跳过
+			continue
+		}
+		data := []byte(event.Data)
+		if event.Event == "error" || bytes.Contains(data, []byte(`"error"`)) {
+			if apiErr := errors.ParseAPIError("stream error", s.resp.StatusCode, data); apiErr != nil {
+				return s.finish(apiErr)
+			}
+			if event.Event == "error" {
+				// 无法解析的错误事件, 原始数据可能包含生成内容, 仅保存在 Body 中
+				return s.finish(&errors.APIError{
+					CodeError: errors.CodeError{Code: http.StatusBadGateway, Message: "unrecognized error event"},
+					Op:        "stream error",
+					Body:      data,
+				})
+			}
+		}
+
+		var chunk T
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return s.finish(errors.NewCodeErrorF(http.StatusBadGateway, "failed to parse response: %v", err))
+		}
+		s.current = chunk
+		if s.observe != nil {
+			s.observe(chunk)
+		}
+		return true
+	}
+}
+
+// finish 结束读取并关闭响应体, 调用时需持有 mu
+func (s *Stream[T]) finish(err error) bool {
+	s.done = true
+	// 读取出错时, ctx 已取消说明是取消导致响应体被关闭; 已正常结束的流不受取消影响
+	if ctxErr := s.ctx.Err(); err != nil && ctxErr != nil {
+		err = ctxErr
+	}
+	s.err = err
+	s.closeBody()
+	s.notifyClose()
+	return false
+}
+
+// Current 返回最近一次 Next 读取到的响应块
+func (s *Stream[T]) Current() T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.current
+}
+
+// Err 返回读取过程中的错误, 正常结束时为 nil
+func (s *Stream[T]) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close 关闭响应体, 可重复调用
+// 先关闭响应体以中断并发的 Next, 待其返回后再执行关闭回调
+func (s *Stream[T]) Close() error {
+	s.closeBody()
+	s.mu.Lock()
+	s.notifyClose()
+	s.mu.Unlock()
+	return s.closeErr
+}
+
+// closeBody 关闭响应体, 只执行一次
+func (s *Stream[T]) closeBody() {
+	s.closeOnce.Do(func() {
+		s.closed.Store(true)
+		s.stop()
+		s.closeErr = s.resp.Body.Close()
+	})
+}
+
+// notifyClose 执行关闭回调, 只执行一次, 调用时需持有 mu
+func (s *Stream[T]) notifyClose() {
+	if s.notified {
+		return
+	}
+	s.notified = true
+	if s.onClose != nil {
+		s.onClose()
+	}
+}
+
+// All 返回迭代器, 依次产出响应块; 出错时产出一次零值与错误后结束
+// 迭代结束或提前 break 时自动关闭流
+func (s *Stream[T]) All() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		defer s.Close()
+		for s.Next() {
+			if !yield(s.Current(), nil) {
+				return
+			}
+		}
+		if err := s.Err(); err != nil {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
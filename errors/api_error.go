@@ -0,0 +1,160 @@
+package errors
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxSnippetSize 非错误格式响应体用作错误信息时的最大字节数
+const maxSnippetSize = 200
+
+// requestIDHeaders 服务端可能返回请求ID的响应头
+var requestIDHeaders = []string{"X-Request-Id", "X-Ds-Request-Id", "X-Trace-Id"}
+
+// APIError DeepSeek 接口返回的错误
+// 内嵌 CodeError, Code 为HTTP状态码, Message 为服务端返回的错误信息
+type APIError struct {
+	CodeError
+	Op        string `json:"op,omitempty"`         // 发生错误的操作, 如 "failed to get models"
+	Status    string `json:"status,omitempty"`     // HTTP状态, 如 "402 Payment Required"
+	Type      string `json:"type,omitempty"`       // 错误类型, 如 "invalid_request_error"
+	ErrCode   string `json:"err_code,omitempty"`   // 服务端错误码, 如 "invalid_api_key"
+	Param     string `json:"param,omitempty"`      // 出错的参数
+	RequestID string `json:"request_id,omitempty"` // 请求ID
+	Body      []byte `json:"-"`                    // 原始响应体
+}
+
+// apiErrorEnvelope DeepSeek 错误响应体
+type apiErrorEnvelope struct {
+	Error *struct {
+		Message string          `json:"message"`
+		Type    string          `json:"type"`
+		Param   json.RawMessage `json:"param"`
+		Code    json.RawMessage `json:"code"`
+	} `json:"error"`
+}
+
+// Error 与 CodeError 保持一致的输出格式, 额外附带操作、错误类型与错误码
+func (e *APIError) Error() string {
+	switch ErrPrintTypeDefault {
+	case ErrPrintTypeJson:
+		bytes, _ := json.Marshal(e)
+		return string(bytes)
+	default:
+		var sb strings.Builder
+		if e.Op != "" {
+			sb.WriteString(e.Op)
+			sb.WriteString(": ")
+		}
+		fmt.Fprintf(&sb, "error code: %d", e.Code)
+		if e.Type != "" {
+			fmt.Fprintf(&sb, " type: %s", e.Type)
+		}
+		if e.ErrCode != "" {
+			fmt.Fprintf(&sb, " err_code: %s", e.ErrCode)
+		}
+		fmt.Fprintf(&sb, " message: %s", e.Message)
+		if e.RequestID != "" {
+			fmt.Fprintf(&sb, " request_id: %s", e.RequestID)
+		}
+		return sb.String()
+	}
+}
+
+// NewAPIError 根据HTTP状态码、响应头与响应体创建 APIError
+// 响应体不是 DeepSeek 错误格式时(如网关返回的HTML页面), 使用响应体开头的片段或HTTP状态作为错误信息, 完整内容保存在 Body 中
+func NewAPIError(op string, statusCode int, status string, header http.Header, body []byte) *APIError {
+	e := &APIError{
+		CodeError: CodeError{Code: statusCode},
+		Op:        op,
+		Status:    status,
+		Body:      body,
+	}
+	for _, h := range requestIDHeaders {
+		if id := header.Get(h); id != "" {
+			e.RequestID = id
+			break
+		}
+	}
+
+	if !e.decodeEnvelope(body) {
+		e.Message = snippet(body)
+	}
+	if e.Message == "" {
+		e.Message = status
+	}
+	return e
+}
+
+// ParseAPIError 解析 DeepSeek 错误格式的数据, 如流式响应中的错误事件
+// 数据不是错误格式时返回 nil
+func ParseAPIError(op string, statusCode int, body []byte) *APIError {
+	e := &APIError{CodeError: CodeError{Code: statusCode}, Op: op, Body: body}
+	if !e.decodeEnvelope(body) {
+		return nil
+	}
+	return e
+}
+
+// decodeEnvelope 解析 {"error": {...}} 格式的错误信息
+func (e *APIError) decodeEnvelope(body []byte) bool {
+	var envelope apiErrorEnvelope
+	if err := json.Unmarshal(body, &envelope); err != nil || envelope.Error == nil {
+		return false
+	}
+	e.Message = envelope.Error.Message
+	e.Type = envelope.Error.Type
+	e.Param = rawString(envelope.Error.Param)
+	e.ErrCode = rawString(envelope.Error.Code)
+	return true
+}
+
+// ReadAPIError 读取APIError
+func ReadAPIError(err error) *APIError {
+	for err != nil {
+		if apiErr, ok := err.(*APIError); ok {
+			return apiErr
+		}
+		unwrapper, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return nil
+		}
+		err = unwrapper.Unwrap()
+	}
+	return nil
+}
+
+// rawString 将 string / number / null 形式的JSON值转换为字符串
+func rawString(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err == nil {
+		return n.String()
+	}
+	return string(raw)
+}
+
+// snippet 返回响应体开头的片段, 控制字符与连续空白合并为一个空格, 超过 maxSnippetSize 时截断
+func snippet(body []byte) string {
+	text := strings.Join(strings.FieldsFunc(string(body), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsControl(r)
+	}), " ")
+	if len(text) <= maxSnippetSize {
+		return text
+	}
+	cut := maxSnippetSize
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + "..."
+}
@@ -61,8 +61,18 @@ func ReadCodeError(err error) *CodeError {
 	if err == nil {
 		return nil
 	}
-	if codeErr, ok := err.(*CodeError); ok {
-		return codeErr
+	for err != nil {
+		switch e := err.(type) {
+		case *CodeError:
+			return e
+		case *APIError:
+			return &e.CodeError
+		}
+		unwrapper, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return nil
+		}
+		err = unwrapper.Unwrap()
 	}
 	return nil
 }
@@ -0,0 +1,134 @@
+package model
+
+import (
+	"net/http"
+
+	"github.com/miajio/dpsk/errors"
+)
+
+const (
+	CurrencyCNY = "CNY" // 人民币
+	CurrencyUSD = "USD" // 美元
+)
+
+// Total 解析总的可用余额
+func (b BalanceInfo) Total() (Decimal, error) {
+	return ParseDecimal(b.TotalBalance)
+}
+
+// Granted 解析未过期的赠金余额
+func (b BalanceInfo) Granted() (Decimal, error) {
+	return ParseDecimal(b.GrantedBalance)
+}
+
+// ToppedUp 解析充值余额
+func (b BalanceInfo) ToppedUp() (Decimal, error) {
+	return ParseDecimal(b.ToppedUpBalance)
+}
+
+// Sub 逐项相减(b - o), 币种不同时返回错误, 可用于计算两次查询间的消耗
+func (b BalanceInfo) Sub(o BalanceInfo) (BalanceInfo, error) {
+	if b.Currency != o.Currency {
+		return BalanceInfo{}, errors.NewCodeErrorF(http.StatusBadRequest, "currency mismatch: %s and %s", b.Currency, o.Currency)
+	}
+	result := BalanceInfo{Currency: b.Currency}
+	fields := []struct {
+		dst      *string
+		lhs, rhs string
+	}{
+		{&result.TotalBalance, b.TotalBalance, o.TotalBalance},
+		{&result.GrantedBalance, b.GrantedBalance, o.GrantedBalance},
+		{&result.ToppedUpBalance, b.ToppedUpBalance, o.ToppedUpBalance},
+	}
+	for _, f := range fields {
+		lhs, err := parseOptional(f.lhs)
+		if err != nil {
+			return BalanceInfo{}, err
+		}
+		rhs, err := parseOptional(f.rhs)
+		if err != nil {
+			return BalanceInfo{}, err
+		}
+		*f.dst = lhs.Sub(rhs).String()
+	}
+	return result, nil
+}
+
+// Cmp 比较总余额, 币种不同时返回错误
+func (b BalanceInfo) Cmp(o BalanceInfo) (int, error) {
+	if b.Currency != o.Currency {
+		return 0, errors.NewCodeErrorF(http.StatusBadRequest, "currency mismatch: %s and %s", b.Currency, o.Currency)
+	}
+	lhs, err := b.Total()
+	if err != nil {
+		return 0, err
+	}
+	rhs, err := o.Total()
+	if err != nil {
+		return 0, err
+	}
+	return lhs.Cmp(rhs), nil
+}
+
+// Info 按币种查找余额信息
+func (b *Balance) Info(currency string) (BalanceInfo, bool) {
+	if b == nil {
+		return BalanceInfo{}, false
+	}
+	for _, info := range b.BalanceInfos {
+		if info.Currency == currency {
+			return info, true
+		}
+	}
+	return BalanceInfo{}, false
+}
+
+// Total 返回指定币种的总余额, 币种不存在时返回错误
+func (b *Balance) Total(currency string) (Decimal, error) {
+	info, ok := b.Info(currency)
+	if !ok {
+		return Decimal{}, errors.NewCodeErrorF(http.StatusNotFound, "balance of currency %s not found", currency)
+	}
+	return info.Total()
+}
+
+// Totals 返回各币种的总余额
+func (b *Balance) Totals() (map[string]Decimal, error) {
+	totals := make(map[string]Decimal)
+	if b == nil {
+		return totals, nil
+	}
+	for _, info := range b.BalanceInfos {
+		total, err := info.Total()
+		if err != nil {
+			return nil, err
+		}
+		totals[info.Currency] = total
+	}
+	return totals, nil
+}
+
+// Sub 按币种逐项相减(b - o), 仅包含两者都有的币种
+func (b *Balance) Sub(o *Balance) (*Balance, error) {
+	result := &Balance{IsAvailable: b.IsAvailable}
+	for _, info := range b.BalanceInfos {
+		other, ok := o.Info(info.Currency)
+		if !ok {
+			continue
+		}
+		diff, err := info.Sub(other)
+		if err != nil {
+			return nil, err
+		}
+		result.BalanceInfos = append(result.BalanceInfos, diff)
+	}
+	return result, nil
+}
+
+// parseOptional 解析可能为空的金额, 空字符串视为0
+func parseOptional(s string) (Decimal, error) {
+	if s == "" {
+		return Decimal{}, nil
+	}
+	return ParseDecimal(s)
+}
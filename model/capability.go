@@ -0,0 +1,105 @@
+package model
+
+import "slices"
+
+const (
+	DeepSeekChat     = "deepseek-chat"     // 对话模型
+	DeepSeekReasoner = "deepseek-reasoner" // 推理模型
+)
+
+// 请求参数名, 与 chat.ChatRequest 的 JSON 字段名一致
+const (
+	ParamTemperature      = "temperature"
+	ParamTopP             = "top_p"
+	ParamPresencePenalty  = "presence_penalty"
+	ParamFrequencyPenalty = "frequency_penalty"
+	ParamLogprobs         = "logprobs"
+	ParamTopLogprobs      = "top_logprobs"
+)
+
+// Capabilities 模型能力与限制
+type Capabilities struct {
+	ID               string   `json:"id"`                // 模型ID
+	OwnedBy          string   `json:"owned_by"`          // 所有者
+	Known            bool     `json:"known"`             // 是否在内置能力表中, 未知模型不做能力校验
+	ContextWindow    int      `json:"context_window"`    // 上下文长度(token)
+	MaxOutput        int      `json:"max_output"`        // 最大输出长度(token), 推理模型包含思维链
+	DefaultOutput    int      `json:"default_output"`    // 未设置 max_tokens 时的默认输出长度
+	Tools            bool     `json:"tools"`             // 是否支持工具调用
+	JSONMode         bool     `json:"json_mode"`         // 是否支持 JSON 输出
+	FIM              bool     `json:"fim"`               // 是否支持 FIM 补全(beta)
+	PrefixCompletion bool     `json:"prefix_completion"` // 是否支持对话前缀续写(beta)
+	Reasoning        bool     `json:"reasoning"`         // 是否为推理模型, 响应包含 reasoning_content
+	IgnoredParams    []string `json:"ignored_params"`    // 可以设置但不生效的参数
+	RejectedParams   []string `json:"rejected_params"`   // 设置后接口会报错的参数
+}
+
+// Ignores 参数是否不生效
+func (c Capabilities) Ignores(param string) bool {
+	return slices.Contains(c.IgnoredParams, param)
+}
+
+// Rejects 参数是否会导致接口报错
+func (c Capabilities) Rejects(param string) bool {
+	return slices.Contains(c.RejectedParams, param)
+}
+
+// Supports 参数是否受支持(既不会被忽略也不会报错)
+func (c Capabilities) Supports(param string) bool {
+	return !c.Ignores(param) && !c.Rejects(param)
+}
+
+// builtinCapabilities DeepSeek 官方模型能力表
+var builtinCapabilities = map[string]Capabilities{
+	DeepSeekChat: {
+		ID:               DeepSeekChat,
+		OwnedBy:          "deepseek",
+		Known:            true,
+		ContextWindow:    128 * 1024,
+		MaxOutput:        8 * 1024,
+		DefaultOutput:    4 * 1024,
+		Tools:            true,
+		JSONMode:         true,
+		FIM:              true,
+		PrefixCompletion: true,
+	},
+	DeepSeekReasoner: {
+		ID:               DeepSeekReasoner,
+		OwnedBy:          "deepseek",
+		Known:            true,
+		ContextWindow:    128 * 1024,
+		MaxOutput:        64 * 1024,
+		DefaultOutput:    32 * 1024,
+		JSONMode:         true,
+		PrefixCompletion: true,
+		Reasoning:        true,
+		IgnoredParams:    []string{ParamTemperature, ParamTopP, ParamPresencePenalty, ParamFrequencyPenalty},
+		RejectedParams:   []string{ParamLogprobs, ParamTopLogprobs},
+	},
+}
+
+// LookupCapabilities 查找内置能力表, 未知模型返回仅包含 ID 的能力且 ok 为 false
+func LookupCapabilities(id string) (Capabilities, bool) {
+	c, ok := builtinCapabilities[id]
+	if !ok {
+		return Capabilities{ID: id}, false
+	}
+	c.IgnoredParams = slices.Clone(c.IgnoredParams)
+	c.RejectedParams = slices.Clone(c.RejectedParams)
+	return c, true
+}
+
+// BuiltinCapabilities 返回内置能力表中的所有模型
+func BuiltinCapabilities() []Capabilities {
+	ids := make([]string, 0, len(builtinCapabilities))
+	for id := range builtinCapabilities {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	caps := make([]Capabilities, 0, len(ids))
+	for _, id := range ids {
+		c, _ := LookupCapabilities(id)
+		caps = append(caps, c)
+	}
+	return caps
+}
@@ -0,0 +1,199 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/miajio/dpsk/errors"
+)
+
+// decimalScale Decimal 的小数位数
+const decimalScale = 9
+
+// decimalUnit 1 对应的内部单位数
+const decimalUnit = 1_000_000_000
+
+// Decimal 定点小数, 精确到小数点后9位, 用于金额计算, 避免浮点误差
+// 零值表示0, 可直接使用
+type Decimal struct {
+	units int64 // 以 1e-9 为单位的值
+}
+
+// ParseDecimal 解析十进制字符串, 如 "110.00"、"-0.5"
+// 小数位超过9位且超出部分不为0时返回错误
+func ParseDecimal(s string) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Decimal{}, errors.NewF("invalid decimal: empty string")
+	}
+	neg := false
+	switch s[0] {
+	case '-':
+		neg = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	if intPart == "" && fracPart == "" {
+		return Decimal{}, errors.NewF("invalid decimal: %q", s)
+	}
+	if len(fracPart) > decimalScale {
+		if strings.Trim(fracPart[decimalScale:], "0") != "" {
+			return Decimal{}, errors.NewF("invalid decimal: %q has more than %d decimal places", s, decimalScale)
+		}
+		fracPart = fracPart[:decimalScale]
+	}
+	for _, part := range []string{intPart, fracPart} {
+		for _, c := range part {
+			if c < '0' || c > '9' {
+				return Decimal{}, errors.NewF("invalid decimal: %q", s)
+			}
+		}
+	}
+
+	var units int64
+	if intPart != "" {
+		n, err := strconv.ParseInt(intPart, 10, 64)
+		if err != nil || n > math.MaxInt64/decimalUnit {
+			return Decimal{}, errors.NewF("invalid decimal: %q out of range", s)
+		}
+		units = n * decimalUnit
+	}
+	if fracPart != "" {
+		frac, _ := strconv.ParseInt(fracPart+strings.Repeat("0", decimalScale-len(fracPart)), 10, 64)
+		units += frac
+	}
+	if neg {
+		units = -units
+	}
+	return Decimal{units: units}, nil
+}
+
+// MustDecimal 解析十进制字符串, 出错时 panic, 适用于常量初始化
+func MustDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// NewDecimal 由整数创建 Decimal
+func NewDecimal(n int64) Decimal {
+	return Decimal{units: n * decimalUnit}
+}
+
+// Add 加法
+func (d Decimal) Add(o Decimal) Decimal {
+	return Decimal{units: d.units + o.units}
+}
+
+// Sub 减法
+func (d Decimal) Sub(o Decimal) Decimal {
+	return Decimal{units: d.units - o.units}
+}
+
+// Neg 取反
+func (d Decimal) Neg() Decimal {
+	return Decimal{units: -d.units}
+}
+
+// MulInt 乘以整数
+func (d Decimal) MulInt(n int64) Decimal {
+	return Decimal{units: d.units * n}
+}
+
+// MulDiv 乘以 n 再除以 div, 结果按四舍五入保留9位小数, 如 price.MulDiv(tokens, 1_000_000)
+func (d Decimal) MulDiv(n, div int64) Decimal {
+	product := d.units * n
+	q, r := product/div, product%div
+	if r < 0 {
+		r = -r
+	}
+	if r*2 >= abs(div) {
+		if (product < 0) != (div < 0) {
+			q--
+		} else {
+			q++
+		}
+	}
+	return Decimal{units: q}
+}
+
+// Cmp 比较大小, d<o 返回-1, d==o 返回0, d>o 返回1
+func (d Decimal) Cmp(o Decimal) int {
+	switch {
+	case d.units < o.units:
+		return -1
+	case d.units > o.units:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Sign 返回符号, 负数-1, 零0, 正数1
+func (d Decimal) Sign() int {
+	return d.Cmp(Decimal{})
+}
+
+// IsZero 是否为0
+func (d Decimal) IsZero() bool {
+	return d.units == 0
+}
+
+// Float64 转换为浮点数, 仅用于展示或非精确计算
+func (d Decimal) Float64() float64 {
+	return float64(d.units) / decimalUnit
+}
+
+// String 返回十进制字符串, 去除末尾多余的0, 至少保留2位小数, 如 "110.00"、"0.000007"
+func (d Decimal) String() string {
+	units := d.units
+	sign := ""
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+	frac := fmt.Sprintf("%09d", units%decimalUnit)
+	frac = strings.TrimRight(frac, "0")
+	for len(frac) < 2 {
+		frac += "0"
+	}
+	return fmt.Sprintf("%s%d.%s", sign, units/decimalUnit, frac)
+}
+
+// MarshalJSON 序列化为JSON字符串
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON 支持JSON字符串与数字
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	v, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// abs 绝对值
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -15,7 +15,7 @@ type ModelList struct {
 
 // Balance 余额结构体
 type Balance struct {
-	IsAvailable  bool          `json:"is_available,omitempty"`
+	IsAvailable  bool          `json:"is_available"`
 	BalanceInfos []BalanceInfo `json:"balance_infos,omitempty"`
 }
 
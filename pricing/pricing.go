@@ -0,0 +1,206 @@
+// Package pricing 根据 chat.Usage 计算请求费用
+// 区分输入缓存命中、缓存未命中与输出token的单价, 支持人民币与美元价格及错峰优惠时段
+package pricing
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/errors"
+	"github.com/miajio/dpsk/model"
+)
+
+const (
+	CNY = model.CurrencyCNY // 人民币
+	USD = model.CurrencyUSD // 美元
+)
+
+// tokensPerUnit 单价对应的token数(每百万token)
+const tokensPerUnit = 1_000_000
+
+// Price 每百万token的单价
+type Price struct {
+	InputCacheHit  model.Decimal `json:"input_cache_hit"`  // 输入(缓存命中)
+	InputCacheMiss model.Decimal `json:"input_cache_miss"` // 输入(缓存未命中)
+	Output         model.Decimal `json:"output"`           // 输出(含思维链)
+}
+
+// DiscountWindow 优惠时段, 时段内按 Percent 百分比计价
+type DiscountWindow struct {
+	Start    time.Duration  // 开始时间, 距当天0点的时长
+	End      time.Duration  // 结束时间, 距当天0点的时长, 小于 Start 时表示跨天
+	Location *time.Location // 时区, 为空时使用UTC
+	Percent  int64          // 优惠时段的价格百分比, 如50表示五折
+}
+
+// Contains 判断时间是否在优惠时段内
+func (w DiscountWindow) Contains(t time.Time) bool {
+	loc := w.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+	if w.Start <= w.End {
+		return offset >= w.Start && offset < w.End
+	}
+	return offset >= w.Start || offset < w.End
+}
+
+// ModelPricing 模型价格
+type ModelPricing struct {
+	Model     string           // 模型名称
+	Prices    map[string]Price // 各币种的单价
+	Discounts []DiscountWindow // 优惠时段
+}
+
+// Table 价格表, 可并发使用
+type Table struct {
+	mu     sync.RWMutex
+	models map[string]ModelPricing
+}
+
+// NewTable 创建价格表
+func NewTable(pricings ...ModelPricing) *Table {
+	t := &Table{models: make(map[string]ModelPricing)}
+	for _, p := range pricings {
+		t.Set(p)
+	}
+	return t
+}
+
+// offPeak DeepSeek 错峰时段: 北京时间 00:30-08:30(UTC 16:30-00:30)
+func offPeak(percent int64) []DiscountWindow {
+	return []DiscountWindow{{
+		Start:   16*time.Hour + 30*time.Minute,
+		End:     30 * time.Minute,
+		Percent: percent,
+	}}
+}
+
+// DefaultTable 返回 DeepSeek 官方价格表(2025-06)
+// 每次调用返回新的价格表, 价格调整后可通过 Set 覆盖, 不影响其他调用方
+func DefaultTable() *Table {
+	return NewTable(
+		ModelPricing{
+			Model: "deepseek-chat",
+			Prices: map[string]Price{
+				CNY: {InputCacheHit: model.MustDecimal("0.5"), InputCacheMiss: model.MustDecimal("2"), Output: model.MustDecimal("8")},
+				USD: {InputCacheHit: model.MustDecimal("0.07"), InputCacheMiss: model.MustDecimal("0.27"), Output: model.MustDecimal("1.10")},
+			},
+			Discounts: offPeak(50),
+		},
+		ModelPricing{
+			Model: "deepseek-reasoner",
+			Prices: map[string]Price{
+				CNY: {InputCacheHit: model.MustDecimal("1"), InputCacheMiss: model.MustDecimal("4"), Output: model.MustDecimal("16")},
+				USD: {InputCacheHit: model.MustDecimal("0.14"), InputCacheMiss: model.MustDecimal("0.55"), Output: model.MustDecimal("2.19")},
+			},
+			Discounts: offPeak(25),
+		},
+	)
+}
+
+// Set 设置模型价格, 保存副本, 之后修改 pricing 不影响价格表
+func (t *Table) Set(pricing ModelPricing) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.models[pricing.Model] = pricing.clone()
+}
+
+// Get 获取模型价格, 返回副本
+func (t *Table) Get(modelName string) (ModelPricing, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	p, ok := t.models[modelName]
+	return p.clone(), ok
+}
+
+// clone 复制单价与优惠时段
+func (p ModelPricing) clone() ModelPricing {
+	p.Prices = maps.Clone(p.Prices)
+	p.Discounts = slices.Clone(p.Discounts)
+	return p
+}
+
+// Amount 单一币种的费用明细
+type Amount struct {
+	InputCacheHit  model.Decimal `json:"input_cache_hit"`  // 输入(缓存命中)费用
+	InputCacheMiss model.Decimal `json:"input_cache_miss"` // 输入(缓存未命中)费用
+	Output         model.Decimal `json:"output"`           // 输出费用
+	Total          model.Decimal `json:"total"`            // 合计
+}
+
+// Charge 一次请求的费用
+type Charge struct {
+	Model   string            `json:"model"`    // 模型名称
+	OffPeak bool              `json:"off_peak"` // 是否处于优惠时段
+	Percent int64             `json:"percent"`  // 计价百分比, 非优惠时段为100
+	Amounts map[string]Amount `json:"amounts"`  // 各币种的费用
+}
+
+// Cost 按价格表计算用量的费用, at 为请求时间, 用于判断优惠时段
+// 用量未区分缓存命中时, 输入token全部按缓存未命中计价
+func (t *Table) Cost(usage chat.Usage, modelName string, at time.Time) (Charge, error) {
+	pricing, ok := t.Get(modelName)
+	if !ok {
+		return Charge{}, errors.NewCodeErrorF(http.StatusBadRequest, "pricing of model %s is unknown", modelName)
+	}
+
+	charge := Charge{Model: modelName, Percent: 100, Amounts: make(map[string]Amount, len(pricing.Prices))}
+	for _, w := range pricing.Discounts {
+		if w.Contains(at) {
+			charge.OffPeak = true
+			charge.Percent = w.Percent
+			break
+		}
+	}
+
+	hit, miss := int64(usage.PromptCacheHitTokens), int64(usage.PromptCacheMissTokens)
+	if hit == 0 && miss == 0 {
+		miss = int64(usage.PromptTokens)
+	}
+	output := int64(usage.CompletionTokens)
+	div := int64(tokensPerUnit * 100)
+	for currency, price := range pricing.Prices {
+		amount := Amount{
+			InputCacheHit:  price.InputCacheHit.MulInt(charge.Percent).MulDiv(hit, div),
+			InputCacheMiss: price.InputCacheMiss.MulInt(charge.Percent).MulDiv(miss, div),
+			Output:         price.Output.MulInt(charge.Percent).MulDiv(output, div),
+		}
+		amount.Total = amount.InputCacheHit.Add(amount.InputCacheMiss).Add(amount.Output)
+		charge.Amounts[currency] = amount
+	}
+	return charge, nil
+}
+
+// Currencies 返回价格表中的所有币种
+func (t *Table) Currencies() []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	set := make(map[string]bool)
+	for _, p := range t.models {
+		for currency := range p.Prices {
+			set[currency] = true
+		}
+	}
+	currencies := make([]string, 0, len(set))
+	for currency := range set {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	return currencies
+}
+
+var defaultTable = DefaultTable()
+
+// Cost 按官方价格表计算用量的费用
+func Cost(usage chat.Usage, modelName string, at time.Time) (Charge, error) {
+	return defaultTable.Cost(usage, modelName, at)
+}
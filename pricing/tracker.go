@@ -0,0 +1,135 @@
+package pricing
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/model"
+)
+
+// Spend 累计花费
+type Spend struct {
+	Requests int                      `json:"requests"` // 请求次数
+	Usage    chat.Usage               `json:"usage"`    // 累计用量
+	Amounts  map[string]model.Decimal `json:"amounts"`  // 各币种的累计费用
+}
+
+// add 累加一次请求
+func (s *Spend) add(usage chat.Usage, charge Charge) {
+	s.Requests++
+	s.Usage.Add(usage)
+	if s.Amounts == nil {
+		s.Amounts = make(map[string]model.Decimal)
+	}
+	for currency, amount := range charge.Amounts {
+		s.Amounts[currency] = s.Amounts[currency].Add(amount.Total)
+	}
+}
+
+// clone 复制, 避免调用方修改内部状态
+func (s *Spend) clone() Spend {
+	c := *s
+	c.Amounts = make(map[string]model.Decimal, len(s.Amounts))
+	for currency, amount := range s.Amounts {
+		c.Amounts[currency] = amount
+	}
+	return c
+}
+
+// Tracker 花费累计器, 按标签(如业务、会话ID)分别统计, 可并发使用
+type Tracker struct {
+	mu    sync.Mutex
+	table *Table
+	total Spend
+	tags  map[string]*Spend
+}
+
+// NewTracker 创建花费累计器, table 为空时使用官方价格表的副本
+func NewTracker(table *Table) *Tracker {
+	if table == nil {
+		table = DefaultTable()
+	}
+	return &Tracker{table: table, tags: make(map[string]*Spend)}
+}
+
+// Table 返回使用的价格表
+func (t *Tracker) Table() *Table {
+	return t.table
+}
+
+// Record 记录一次请求的用量, 返回本次费用; tag 为空时仅计入总计
+func (t *Tracker) Record(tag string, modelName string, usage chat.Usage, at time.Time) (Charge, error) {
+	charge, err := t.table.Cost(usage, modelName, at)
+	if err != nil {
+		return charge, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.total.add(usage, charge)
+	if tag != "" {
+		spend, ok := t.tags[tag]
+		if !ok {
+			spend = &Spend{}
+			t.tags[tag] = spend
+		}
+		spend.add(usage, charge)
+	}
+	return charge, nil
+}
+
+// Total 返回总计
+func (t *Tracker) Total() Spend {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.total.clone()
+}
+
+// Tag 返回标签的累计花费
+func (t *Tracker) Tag(tag string) Spend {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if spend, ok := t.tags[tag]; ok {
+		return spend.clone()
+	}
+	return Spend{Amounts: make(map[string]model.Decimal)}
+}
+
+// Tags 返回所有标签
+func (t *Tracker) Tags() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tags := make([]string, 0, len(t.tags))
+	for tag := range t.tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// Reset 清空统计
+func (t *Tracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.total = Spend{}
+	t.tags = make(map[string]*Spend)
+}
+
+// Remaining 用余额减去累计花费, 返回各币种的估算剩余余额
+// balance 为统计开始时通过 GetBalance 获取的余额, 余额格式错误的币种会被跳过
+func (t *Tracker) Remaining(balance *model.Balance) map[string]model.Decimal {
+	total := t.Total()
+	remaining := make(map[string]model.Decimal)
+	if balance == nil {
+		return remaining
+	}
+	for _, info := range balance.BalanceInfos {
+		amount, err := info.Total()
+		if err != nil {
+			continue
+		}
+		remaining[info.Currency] = amount.Sub(total.Amounts[info.Currency])
+	}
+	return remaining
+}
@@ -0,0 +1,66 @@
+package tokenizer
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/miajio/dpsk/chat"
+	"github.com/miajio/dpsk/errors"
+	"github.com/miajio/dpsk/model"
+)
+
+const (
+	PerMessageTokens = 4 // 每条消息的模板开销(角色标记与分隔符)
+	PerRequestTokens = 3 // 每个请求的模板开销(起始标记与助手回复前缀)
+)
+
+// CountMessages 计算消息的token数, 包含每条消息的模板开销
+func CountMessages(counter Counter, msgs []chat.Message) int {
+	tokens := 0
+	for _, msg := range msgs {
+		tokens += PerMessageTokens
+		tokens += counter.CountTokens(msg.Content)
+		tokens += counter.CountTokens(msg.ReasoningContent)
+		tokens += counter.CountTokens(msg.Name)
+		for _, call := range msg.ToolCalls {
+			tokens += counter.CountTokens(call.Function.Name) + counter.CountTokens(call.Function.Arguments)
+		}
+	}
+	return tokens
+}
+
+// MessagesCounter 返回按 counter 计算消息token数的函数, 可用于 conversation.TokenBudget 等
+func MessagesCounter(counter Counter) func(msgs []chat.Message) int {
+	return func(msgs []chat.Message) int {
+		return CountMessages(counter, msgs)
+	}
+}
+
+// CountRequest 计算请求的输入token数, 包含消息、工具定义与模板开销
+func CountRequest(counter Counter, req *chat.ChatRequest) int {
+	tokens := PerRequestTokens + CountMessages(counter, req.Messages)
+	if len(req.Tools) > 0 {
+		tools, _ := json.Marshal(req.Tools)
+		tokens += counter.CountTokens(string(tools))
+	}
+	return tokens
+}
+
+// CheckRequest 校验请求的输入token数与 MaxTokens 之和是否超过上下文长度, 返回输入token数
+// contextLimit 小于等于0时使用 model 能力表中的上下文长度, 未知模型不做校验
+func CheckRequest(counter Counter, req *chat.ChatRequest, contextLimit int) (int, error) {
+	promptTokens := CountRequest(counter, req)
+	if contextLimit <= 0 {
+		caps, _ := model.LookupCapabilities(req.Model)
+		contextLimit = caps.ContextWindow
+	}
+	if contextLimit <= 0 {
+		return promptTokens, nil
+	}
+	if promptTokens+req.MaxTokens > contextLimit {
+		return promptTokens, errors.NewCodeErrorF(http.StatusBadRequest,
+			"request exceeds context length of model %s: prompt %d + max_tokens %d > %d",
+			req.Model, promptTokens, req.MaxTokens, contextLimit)
+	}
+	return promptTokens, nil
+}
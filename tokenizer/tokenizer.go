@@ -0,0 +1,250 @@
+// Package tokenizer 在本地估算 DeepSeek 模型的token数
+//
+// Tokenizer 加载 HuggingFace 格式的 tokenizer.json(字节级BPE, 可从 DeepSeek 官方模型仓库下载),
+// 按与官方分词器相同的词表与合并规则编码文本; 未加载词表时可使用 Estimator 按字符数估算
+package tokenizer
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/miajio/dpsk/errors"
+)
+
+// Counter token计数器
+type Counter interface {
+	CountTokens(text string) int
+}
+
+// Estimator 按字符数估算token数, 不需要词表
+// 参照 DeepSeek 文档: 1个英文字符约0.3个token, 1个中文字符约0.6个token
+type Estimator struct{}
+
+// CountTokens 估算文本token数
+func (Estimator) CountTokens(text string) int {
+	var tokens float64
+	for len(text) > 0 {
+		r, size := utf8.DecodeRuneInString(text)
+		text = text[size:]
+		if r >= 0x2E80 {
+			tokens += 0.6
+		} else {
+			tokens += 0.3
+		}
+	}
+	return int(tokens + 0.5)
+}
+
+// preTokenizePattern 预分词规则, 依次匹配 中日文字符、最多3位的数字、单词、空白与标点
+// Go 的正则不支持负向先行断言, 与官方分词器在连续空白的切分上可能略有差异
+var preTokenizePattern = regexp.MustCompile(
+	`[一-龥぀-ゟ゠-ヿ]+|\p{N}{1,3}|[^\r\n\p{L}\p{N}]?\p{L}+|\s*[\r\n]+|\s+|[^\s\p{L}\p{N}]+[\r\n]*`,
+)
+
+// Tokenizer 字节级BPE分词器
+type Tokenizer struct {
+	vocab       map[string]int
+	ranks       map[[2]string]int
+	addedTokens map[string]int
+	addedRegexp *regexp.Regexp
+	byteEncoder [256]string
+
+	cacheMu sync.RWMutex
+	cache   map[string][]int
+}
+
+// maxCacheSize 分词缓存的最大条目数
+const maxCacheSize = 100000
+
+// tokenizerFile tokenizer.json 文件结构
+type tokenizerFile struct {
+	AddedTokens []struct {
+		ID      int    `json:"id"`
+		Content string `json:"content"`
+	} `json:"added_tokens"`
+	Model struct {
+		Type   string            `json:"type"`
+		Vocab  map[string]int    `json:"vocab"`
+		Merges []json.RawMessage `json:"merges"`
+	} `json:"model"`
+}
+
+// LoadFile 从文件加载 tokenizer.json
+func LoadFile(path string) (*Tokenizer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+// Load 读取 HuggingFace 格式的 tokenizer.json
+func Load(r io.Reader) (*Tokenizer, error) {
+	var file tokenizerFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, errors.NewCodeErrorF(http.StatusBadRequest, "invalid tokenizer file: %v", err)
+	}
+	if file.Model.Type != "" && file.Model.Type != "BPE" {
+		return nil, errors.NewCodeErrorF(http.StatusBadRequest, "unsupported tokenizer model type: %s", file.Model.Type)
+	}
+	if len(file.Model.Vocab) == 0 {
+		return nil, errors.NewCodeError(http.StatusBadRequest, "tokenizer vocab is empty")
+	}
+
+	t := &Tokenizer{
+		vocab:       file.Model.Vocab,
+		ranks:       make(map[[2]string]int, len(file.Model.Merges)),
+		addedTokens: make(map[string]int),
+		cache:       make(map[string][]int),
+	}
+	for i, raw := range file.Model.Merges {
+		pair, err := parseMerge(raw)
+		if err != nil {
+			return nil, err
+		}
+		t.ranks[pair] = i
+	}
+
+	var added []string
+	for _, token := range file.AddedTokens {
+		t.addedTokens[token.Content] = token.ID
+		added = append(added, regexp.QuoteMeta(token.Content))
+	}
+	if len(added) > 0 {
+		// 长的特殊token优先匹配
+		sort.Slice(added, func(i, j int) bool { return len(added[i]) > len(added[j]) })
+		t.addedRegexp = regexp.MustCompile(strings.Join(added, "|"))
+	}
+
+	t.byteEncoder = bytesToUnicode()
+	return t, nil
+}
+
+// parseMerge 解析合并规则, 支持 "a b" 与 ["a", "b"] 两种格式
+func parseMerge(raw json.RawMessage) ([2]string, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		left, right, ok := strings.Cut(s, " ")
+		if !ok {
+			return [2]string{}, errors.NewCodeErrorF(http.StatusBadRequest, "invalid merge: %s", s)
+		}
+		return [2]string{left, right}, nil
+	}
+	var pair []string
+	if err := json.Unmarshal(raw, &pair); err != nil || len(pair) != 2 {
+		return [2]string{}, errors.NewCodeErrorF(http.StatusBadRequest, "invalid merge: %s", raw)
+	}
+	return [2]string{pair[0], pair[1]}, nil
+}
+
+// bytesToUnicode 字节级BPE的字节到可见字符映射(与 GPT-2 相同)
+func bytesToUnicode() [256]string {
+	var table [256]string
+	n := 0
+	for b := 0; b < 256; b++ {
+		if (b >= '!' && b <= '~') || (b >= 0xA1 && b <= 0xAC) || (b >= 0xAE && b <= 0xFF) {
+			table[b] = string(rune(b))
+		} else {
+			table[b] = string(rune(256 + n))
+			n++
+		}
+	}
+	return table
+}
+
+// Encode 将文本编码为token ID
+func (t *Tokenizer) Encode(text string) []int {
+	var ids []int
+	if t.addedRegexp == nil {
+		return t.encodeOrdinary(text, ids)
+	}
+	last := 0
+	for _, loc := range t.addedRegexp.FindAllStringIndex(text, -1) {
+		ids = t.encodeOrdinary(text[last:loc[0]], ids)
+		ids = append(ids, t.addedTokens[text[loc[0]:loc[1]]])
+		last = loc[1]
+	}
+	return t.encodeOrdinary(text[last:], ids)
+}
+
+// CountTokens 计算文本token数
+func (t *Tokenizer) CountTokens(text string) int {
+	return len(t.Encode(text))
+}
+
+// encodeOrdinary 编码不含特殊token的文本
+func (t *Tokenizer) encodeOrdinary(text string, ids []int) []int {
+	for _, word := range preTokenizePattern.FindAllString(text, -1) {
+		ids = append(ids, t.encodeWord(word)...)
+	}
+	return ids
+}
+
+// encodeWord 对预分词得到的单词执行BPE合并
+func (t *Tokenizer) encodeWord(word string) []int {
+	t.cacheMu.RLock()
+	cached, ok := t.cache[word]
+	t.cacheMu.RUnlock()
+	if ok {
+		return cached
+	}
+
+	symbols := make([]string, 0, len(word))
+	for i := 0; i < len(word); i++ {
+		symbols = append(symbols, t.byteEncoder[word[i]])
+	}
+
+	for len(symbols) > 1 {
+		best, bestRank := -1, 0
+		for i := 0; i < len(symbols)-1; i++ {
+			rank, ok := t.ranks[[2]string{symbols[i], symbols[i+1]}]
+			if ok && (best < 0 || rank < bestRank) {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		pair := [2]string{symbols[best], symbols[best+1]}
+		merged := symbols[:0:0]
+		for i := 0; i < len(symbols); i++ {
+			if i < len(symbols)-1 && symbols[i] == pair[0] && symbols[i+1] == pair[1] {
+				merged = append(merged, pair[0]+pair[1])
+				i++
+				continue
+			}
+			merged = append(merged, symbols[i])
+		}
+		symbols = merged
+	}
+
+	ids := make([]int, 0, len(symbols))
+	for _, symbol := range symbols {
+		if id, ok := t.vocab[symbol]; ok {
+			ids = append(ids, id)
+			continue
+		}
+		// 词表缺少合并结果时退回到单字节token
+		for _, r := range symbol {
+			if id, ok := t.vocab[string(r)]; ok {
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	t.cacheMu.Lock()
+	if len(t.cache) >= maxCacheSize {
+		t.cache = make(map[string][]int)
+	}
+	t.cache[word] = ids
+	t.cacheMu.Unlock()
+	return ids
+}